@@ -10,21 +10,34 @@ import (
 type Env struct {
 	Vars   map[types.Symbol]any
 	Parent *Env
+	// The interpreter using the environment, or the state of the evaluation,
+	// it is inherited by the child environments
+	Owner any
+	// changes each time a name is bound
+	version uint64
 }
 
 func NewEnv() *Env {
 	vars := make(map[types.Symbol]any)
-	return &Env{vars, nil}
+	return &Env{Vars: vars}
 }
 
 func NewEnvFrom(parent *Env) *Env {
 	new := NewEnv()
 	new.Parent = parent
+	new.Owner = parent.Owner
 	return new
 }
 
 func (e *Env) Set(name types.Symbol, value any) {
 	e.Vars[name] = value
+	e.version++
+}
+
+// The number of times the names were bound in the environment, when it
+// did not change, the values in it are the same
+func (e *Env) Version() uint64 {
+	return e.version
 }
 
 // Find an enclosing environment for the variable
@@ -77,3 +77,12 @@ func TestNestedEnvGet(t *testing.T) {
 		}
 	}
 }
+
+func TestVersion(t *testing.T) {
+	env := NewEnv()
+	before := env.Version()
+	env.Set(types.Symbol("x"), 1)
+	if env.Version() == before {
+		t.Errorf("the version did not change after binding the name")
+	}
+}
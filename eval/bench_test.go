@@ -0,0 +1,195 @@
+package eval
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+const benchAppendo = `
+(define appendo
+	(lambda (l s out)
+		(conde
+			((== '() l) (== s out))
+			((fresh (a d res)
+				(== (cons a d) l)
+				(== (cons a res) out)
+				(appendo d s res))))))
+`
+
+const benchPeano = `
+(define pluso
+	(lambda (n m sum)
+		(conde
+			((== 'z n) (== m sum))
+			((fresh (x y)
+				(== (list 's x) n)
+				(== (list 's y) sum)
+				(pluso x m y))))))
+
+(define plus*o
+	(lambda (in* out)
+		(conde
+			((== '() in*) (== 'z out))
+			((fresh (a d res)
+				(== (cons a d) in*)
+				(pluso a res out)
+				(plus*o d res))))))
+
+(define int->peano
+	(lambda (n)
+		(cond
+			((= n 0) 'z)
+			(else (list 's (int->peano (- n 1)))))))
+`
+
+// The zebra puzzle, the houses are lists of (color nationality drink smoke pet)
+const benchZebra = `
+(define membero
+	(lambda (x l)
+		(fresh (a d)
+			(== (cons a d) l)
+			(conde
+				((== a x))
+				((membero x d))))))
+
+(define righto
+	(lambda (x y l)
+		(fresh (a d)
+			(== (cons a d) l)
+			(conde
+				((fresh (b e)
+					(== (cons b e) d)
+					(== a x)
+					(== b y)))
+				((righto x y d))))))
+
+(define nexto
+	(lambda (x y l)
+		(conde
+			((righto x y l))
+			((righto y x l)))))
+
+(define zebrao
+	(lambda (h)
+		(fresh (a1 a2 a3 a4 a5 b1 b2 b3 b4 b5 c1 c2 c4 c5 d1 d2 d3 d4 d5 e1 e2 e3 e4 e5)
+			(== h (list (list a1 'norwegian a2 a3 a4) b1 (list c1 c2 'milk c4 c5) d1 e1))
+			(fresh (a b c) (membero (list 'red 'english a b c) h))
+			(fresh (a b c) (membero (list a 'spaniard b c 'dog) h))
+			(fresh (a b c) (membero (list 'green a 'coffee b c) h))
+			(fresh (a b c) (membero (list a 'ukrainian 'tea b c) h))
+			(fresh (a b c d e f g h2)
+				(righto (list 'ivory a b c d) (list 'green e f g h2) h))
+			(fresh (a b c) (membero (list a b c 'oldgold 'snails) h))
+			(fresh (a b c) (membero (list 'yellow a b 'kools c) h))
+			(fresh (a b c d e f g h2)
+				(nexto (list a b c 'chesterfield d) (list e f g h2 'fox) h))
+			(fresh (a b c d e f g h2)
+				(nexto (list a b c 'kools d) (list e f g h2 'horse) h))
+			(fresh (a b c) (membero (list a b 'oj 'luckystrike c) h))
+			(fresh (a b c) (membero (list a 'japanese b 'parliaments c) h))
+			(fresh (a b c d e f g h2)
+				(nexto (list a 'norwegian b c d) (list 'blue e f g h2) h))
+			(fresh (a b c d) (membero (list a b 'water c d) h))
+			(fresh (a b c d) (membero (list a b c d 'zebra) h)))))
+`
+
+func benchmarkQuery(b *testing.B, defs, query, expected string) {
+	env := DefaultEnv()
+	if _, _, err := EvalString(defs, env); err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, _, err := EvalString(query, env)
+		if err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		if expected != "" {
+			if got := types.ToString(result[len(result)-1]); got != expected {
+				b.Fatalf("expected %s, got %s", expected, got)
+			}
+		}
+	}
+}
+
+// The sizes of the inputs, to show how the run time grows with the number of bindings
+var benchSizes = []int{100, 300, 1000}
+
+func BenchmarkAppendo(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			benchmarkQuery(b, benchAppendo,
+				fmt.Sprintf("(run 1 (q) (appendo (range 1 %d) (range 1 %d) q))", n, n),
+				"")
+		})
+	}
+}
+
+func BenchmarkPeano(b *testing.B) {
+	for _, n := range benchSizes {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			benchmarkQuery(b, benchPeano,
+				fmt.Sprintf("(run 1 (q) (plus*o (list (int->peano %d) (int->peano %d)) q))", n, n),
+				"")
+		})
+	}
+}
+
+func BenchmarkZebra(b *testing.B) {
+	benchmarkQuery(b, benchZebra,
+		"(run* (h) (zebrao h))",
+		"(((yellow norwegian water kools fox) (blue ukrainian tea chesterfield horse) "+
+			"(red english milk oldgold snails) (ivory spaniard oj luckystrike dog) "+
+			"(green japanese coffee parliaments zebra)))")
+}
+
+// The alist that was used for the substitution before, kept as the baseline,
+// the lookups scan it backwards and extending the forked stream copies it
+type alist []KeyVal
+
+func (l alist) get(key types.Variable) (any, bool) {
+	for i := len(l) - 1; i >= 0; i-- {
+		if l[i].key == key {
+			return l[i].val, true
+		}
+	}
+	return nil, false
+}
+
+func (l alist) set(key types.Variable, val any) alist {
+	return append(limited(l), KeyVal{key, val})
+}
+
+// Extend the forked substitutions, as the search does, and look up the earlier bindings
+func BenchmarkSubstitution(b *testing.B) {
+	for _, n := range benchSizes {
+		vars := make([]types.Variable, n)
+		for i := range vars {
+			vars[i] = types.NewVariable("x")
+		}
+		b.Run(fmt.Sprintf("alist/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var s alist
+				for j, v := range vars {
+					s = s.set(v, j)
+					if _, ok := s.get(vars[j/2]); !ok {
+						b.Fatalf("missing binding for %v", vars[j/2])
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("trie/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var s substitution
+				for j, v := range vars {
+					s = s.set(v, j)
+					if _, ok := s.get(vars[j/2]); !ok {
+						b.Fatalf("missing binding for %v", vars[j/2])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,538 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/types"
+)
+
+// The goal expressions are compiled once to the goal code, that is instantiated
+// to the goals for the values of the variables, without evaluating the
+// expressions again. The logic variables and the arguments of the relations
+// are looked up by their lexical address, the depth of the scope and the index
+// in it. The expressions that cannot be compiled are evaluated as Scheme code
+// when the goal is instantiated.
+type goalCode interface {
+	instantiate(*frame) (Goal, error)
+}
+
+// The names defined by fresh, project, or the arguments of the relation
+type scope struct {
+	names  []types.Symbol
+	parent *scope
+}
+
+// Find the lexical address of the name
+func (sc *scope) lookup(name types.Symbol) (int, int, bool) {
+	for depth := 0; sc != nil; depth++ {
+		for i := len(sc.names) - 1; i >= 0; i-- {
+			if sc.names[i] == name {
+				return depth, i, true
+			}
+		}
+		sc = sc.parent
+	}
+	return 0, 0, false
+}
+
+func (sc *scope) defines(name types.Symbol) bool {
+	_, _, ok := sc.lookup(name)
+	return ok
+}
+
+// The values for the scope, the names outside of it are looked up in the env
+type frame struct {
+	names  []types.Symbol
+	vals   []any
+	parent *frame
+	env    *envir.Env
+}
+
+func (f *frame) get(depth, index int) any {
+	for ; depth > 0; depth-- {
+		f = f.parent
+	}
+	return f.vals[index]
+}
+
+func (f *frame) child(names []types.Symbol, vals []any) *frame {
+	return &frame{names, vals, f, f.env}
+}
+
+// Environment with the values from the frames, for evaluating the Scheme code
+func (f *frame) toEnv() *envir.Env {
+	local := envir.NewEnvFrom(f.env)
+	f.define(local)
+	return local
+}
+
+func (f *frame) define(env *envir.Env) {
+	if f == nil {
+		return
+	}
+	f.parent.define(env)
+	for i, name := range f.names {
+		env.Set(name, f.vals[i])
+	}
+}
+
+// Goal constructor, like `==` or `conde`, that is compiled before creating the goal
+type goalForm struct {
+	name    string
+	compile func(args any, sc *scope, env *envir.Env) (goalCode, error)
+}
+
+// Create the goal when the form is called from the Scheme code
+func (g goalForm) eval(args any, env *envir.Env) (any, error) {
+	code, err := g.compile(args, nil, env)
+	if err != nil {
+		return nil, err
+	}
+	return code.instantiate(&frame{env: env})
+}
+
+func (g goalForm) String() string {
+	return fmt.Sprintf("<goal %s>", g.name)
+}
+
+// Check if the expression is a call to one of the goal forms
+func isGoalForm(expr any, sc *scope, env *envir.Env) bool {
+	expr, err := expandMacros(expr, sc, env)
+	if err != nil {
+		return false
+	}
+	_, ok := lookupForm(expr, sc, env)
+	return ok
+}
+
+func lookupForm(expr any, sc *scope, env *envir.Env) (goalForm, bool) {
+	p, ok := expr.(types.Pair)
+	if !ok {
+		return goalForm{}, false
+	}
+	name, ok := p.This.(types.Symbol)
+	if !ok || sc.defines(name) {
+		return goalForm{}, false
+	}
+	val, ok := lookup(name, env)
+	if !ok {
+		return goalForm{}, false
+	}
+	form, ok := val.(goalForm)
+	return form, ok
+}
+
+func compileGoal(expr any, sc *scope, env *envir.Env) (goalCode, error) {
+	expr, err := expandMacros(expr, sc, env)
+	if err != nil {
+		return nil, err
+	}
+	if form, ok := lookupForm(expr, sc, env); ok {
+		return form.compile(expr.(types.Pair).Next, sc, env)
+	}
+	switch e := expr.(type) {
+	case types.Symbol:
+		return goalValue{compileTerm(e, sc, env)}, nil
+	case types.Pair:
+		if name, ok := e.This.(types.Symbol); ok {
+			if !sc.defines(name) {
+				val, ok := lookup(name, env)
+				switch unwrap(val).(type) {
+				case *Lambda, Procedure:
+				default:
+					if ok {
+						// special form
+						return evalGoal{expr}, nil
+					}
+				}
+			}
+			args, err := compileArgs(e.Next, sc, env)
+			if err != nil {
+				return nil, err
+			}
+			return callCode{compileTerm(name, sc, env), args, expr}, nil
+		}
+	}
+	return evalGoal{expr}, nil
+}
+
+func compileGoals(body any, sc *scope, env *envir.Env) ([]goalCode, error) {
+	var (
+		acc  []goalCode
+		head = body
+	)
+	for head != nil {
+		p, ok := head.(types.Pair)
+		if !ok {
+			return nil, SyntaxError
+		}
+		code, err := compileGoal(p.This, sc, env)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, code)
+		head = p.Next
+	}
+	return acc, nil
+}
+
+func instantiateAll(codes []goalCode, f *frame) ([]Goal, error) {
+	var acc []Goal
+	for _, code := range codes {
+		g, err := code.instantiate(f)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, g)
+	}
+	return acc, nil
+}
+
+func toGoal(val any) (Goal, error) {
+	g, ok := val.(Goal)
+	if !ok {
+		return nil, WrongArg{val}
+	}
+	return g, nil
+}
+
+// The expression that evaluates to a goal, like `succeed` or the argument of the relation
+type goalValue struct {
+	term term
+}
+
+func (c goalValue) instantiate(f *frame) (Goal, error) {
+	val, err := c.term.value(f)
+	if err != nil {
+		return nil, err
+	}
+	return toGoal(val)
+}
+
+type vectorTerm struct {
+	elems []term
+}
+
+func (t vectorTerm) value(f *frame) (any, error) {
+	elems, err := values(t.elems, f)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Vector{Elems: elems, Const: true}, nil
+}
+
+// The expression that is evaluated as Scheme code
+type evalGoal struct {
+	expr any
+}
+
+func (c evalGoal) instantiate(f *frame) (Goal, error) {
+	val, err := Eval(c.expr, f.toEnv())
+	if err != nil {
+		return nil, err
+	}
+	return toGoal(val)
+}
+
+// Call to the relation `(name args ...)`
+type callCode struct {
+	callee term
+	args   []term
+	expr   any
+}
+
+func (c callCode) instantiate(f *frame) (Goal, error) {
+	callee, err := c.callee.value(f)
+	if err != nil {
+		return nil, err
+	}
+	switch unwrap(callee).(type) {
+	case *Lambda, Procedure:
+	default:
+		return evalGoal{c.expr}.instantiate(f)
+	}
+	vals, err := values(c.args, f)
+	if err != nil {
+		return nil, err
+	}
+	var val any
+	switch fn := unwrap(callee).(type) {
+	case Procedure:
+		val, err = tailCall{fn, vals}.apply(f.env)
+	case *Lambda:
+		if fn.goal != nil {
+			// the body is instantiated only when it is queried,
+			// so the recursive relations are not expanded ahead
+			return Call{c.expr.(types.Pair).This, fn, vals}, nil
+		}
+		val, err = fn.apply(vals)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toGoal(val)
+}
+
+// Call to the compiled relation
+type Call struct {
+	name any
+	fn   *Lambda
+	args []any
+}
+
+func (g Call) Query(s *Stream) (Answers, error) {
+	goal, err := g.fn.instantiate(g.args)
+	if err != nil {
+		return nil, err
+	}
+	return goal.Query(s)
+}
+
+func (g Call) String() string {
+	var args []string
+	for _, a := range g.args {
+		args = append(args, types.ToString(a))
+	}
+	return fmt.Sprintf("(%v %s)", types.ToString(g.name), strings.Join(args, " "))
+}
+
+// Goal with the arguments that are evaluated when it is instantiated
+type primitive struct {
+	make func([]any) (Goal, error)
+	args []term
+}
+
+func (c primitive) instantiate(f *frame) (Goal, error) {
+	vals, err := values(c.args, f)
+	if err != nil {
+		return nil, err
+	}
+	return c.make(vals)
+}
+
+// The form for the goal created from the values of its arguments,
+// the arity < 0 means at least -arity arguments
+func newPrimitive(name string, arity int, make func([]any) (Goal, error)) goalForm {
+	return goalForm{name, func(args any, sc *scope, env *envir.Env) (goalCode, error) {
+		terms, err := compileArgs(args, sc, env)
+		if err != nil {
+			return nil, err
+		}
+		if (arity >= 0 && len(terms) != arity) || (arity < 0 && len(terms) < -arity) {
+			return nil, ArityError
+		}
+		return primitive{make, terms}, nil
+	}}
+}
+
+// The expression that evaluates to a value
+type term interface {
+	value(*frame) (any, error)
+}
+
+type constant struct {
+	val any
+}
+
+func (t constant) value(*frame) (any, error) {
+	return t.val, nil
+}
+
+// The name defined in the scope, at its lexical address
+type slot struct {
+	depth, index int
+}
+
+func (t slot) value(f *frame) (any, error) {
+	return f.get(t.depth, t.index), nil
+}
+
+// The name defined outside of the scope, its value is frozen once
+// for the binding, unless it has the vectors that can still change
+type global struct {
+	name  types.Symbol
+	bound atomic.Pointer[binding]
+}
+
+// The frozen value of the name in the environment
+type binding struct {
+	env     *envir.Env
+	version uint64
+	val     any
+}
+
+func (t *global) value(f *frame) (any, error) {
+	env, name, ok := findEnv(t.name, f.env)
+	if !ok {
+		return nil, fmt.Errorf("unbound variable %v", original(t.name))
+	}
+	if b := t.bound.Load(); b != nil && b.env == env && b.version == env.Version() {
+		return b.val, nil
+	}
+	if _, ok := env.Vars[name].(unassigned); ok {
+		return nil, fmt.Errorf("variable %v is used before its definition", original(t.name))
+	}
+	val, copied := types.FreezeCopy(env.Vars[name])
+	if !copied {
+		t.bound.Store(&binding{env, env.Version(), val})
+	}
+	return val, nil
+}
+
+type consTerm struct {
+	head, tail term
+}
+
+func (t consTerm) value(f *frame) (any, error) {
+	head, err := t.head.value(f)
+	if err != nil {
+		return nil, err
+	}
+	tail, err := t.tail.value(f)
+	if err != nil {
+		return nil, err
+	}
+	return types.Cons(head, tail), nil
+}
+
+// The expression that is evaluated as Scheme code
+type expression struct {
+	expr any
+}
+
+func (t expression) value(f *frame) (any, error) {
+	val, err := Eval(t.expr, f.toEnv())
+	return types.Freeze(val), err
+}
+
+func compileTerm(expr any, sc *scope, env *envir.Env) term {
+	switch e := expr.(type) {
+	case types.Symbol:
+		if depth, index, ok := sc.lookup(e); ok {
+			return slot{depth, index}
+		}
+		return &global{name: e}
+	case types.Pair:
+		name, ok := e.This.(types.Symbol)
+		if !ok || sc.defines(name) {
+			return expression{expr}
+		}
+		fn, _ := lookup(name, env)
+		args, ok := e.Next.(types.Pair)
+		switch {
+		case !ok:
+		case isBuiltin(fn, quote) && args.Next == nil:
+			return constant{args.This}
+		case isBuiltin(fn, quasiQuote) && args.Next == nil:
+			return compileQuasiquote(args.This, 1, sc, env)
+		case isBuiltin(fn, cons) && args.Len() == 2:
+			return consTerm{
+				compileTerm(args.This, sc, env),
+				compileTerm(args.Next.(types.Pair).This, sc, env),
+			}
+		case isBuiltin(fn, list):
+			return compileList(args, sc, env)
+		case isBuiltin(fn, vector):
+			if elems, err := compileArgs(args, sc, env); err == nil {
+				return vectorTerm{elems}
+			}
+		}
+		return expression{expr}
+	default:
+		return constant{expr}
+	}
+}
+
+func compileArgs(args any, sc *scope, env *envir.Env) ([]term, error) {
+	var (
+		acc  []term
+		head = args
+	)
+	for head != nil {
+		p, ok := head.(types.Pair)
+		if !ok {
+			return nil, SyntaxError
+		}
+		acc = append(acc, compileTerm(p.This, sc, env))
+		head = p.Next
+	}
+	return acc, nil
+}
+
+func compileList(args any, sc *scope, env *envir.Env) term {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return constant{nil}
+	}
+	return consTerm{compileTerm(p.This, sc, env), compileList(p.Next, sc, env)}
+}
+
+// Compile the quasiquoted value, as in unquoteRecursively
+func compileQuasiquote(val any, numQuotes int, sc *scope, env *envir.Env) term {
+	if v, ok := val.(*types.Vector); ok {
+		var (
+			elems    []term
+			unquoted bool
+		)
+		for _, e := range v.Elems {
+			t := compileQuasiquote(e, numQuotes, sc, env)
+			if _, ok := t.(constant); !ok {
+				unquoted = true
+			}
+			elems = append(elems, t)
+		}
+		if !unquoted {
+			return constant{v}
+		}
+		return vectorTerm{elems}
+	}
+	p, ok := val.(types.Pair)
+	if !ok {
+		return constant{val}
+	}
+	if sym, ok := p.This.(types.Symbol); ok {
+		switch sym {
+		case "quasiquote":
+			numQuotes++
+		case "unquote":
+			numQuotes--
+			if numQuotes == 0 {
+				if args, ok := p.Next.(types.Pair); ok && args.Next == nil {
+					return compileTerm(args.This, sc, env)
+				}
+				return expression{types.List(types.Symbol("quasiquote"), val)}
+			}
+		}
+	}
+	head := compileQuasiquote(p.This, numQuotes, sc, env)
+	tail := compileQuasiquote(p.Next, numQuotes, sc, env)
+	if h, ok := head.(constant); ok {
+		if t, ok := tail.(constant); ok {
+			// nothing was unquoted
+			return constant{types.Cons(h.val, t.val)}
+		}
+	}
+	return consTerm{head, tail}
+}
+
+func values(terms []term, f *frame) ([]any, error) {
+	var acc []any
+	for _, t := range terms {
+		v, err := t.value(f)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, v)
+	}
+	return acc, nil
+}
+
+// Check if the value is the builtin procedure or special form
+func isBuiltin(val, fn any) bool {
+	v := reflect.ValueOf(unwrap(val))
+	return v.Kind() == reflect.Func && v.Pointer() == reflect.ValueOf(fn).Pointer()
+}
@@ -0,0 +1,307 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+// The bindings that would make the disequality constraint violated,
+// the constraint holds as long as at least one of them does not hold
+type Prefix []KeyVal
+
+// Unify the values and check if the constraints still hold
+func (s *Stream) unifyVerify(u, v any) bool {
+	start := s.subst
+	if !s.unify(u, v) {
+		return false
+	}
+	if s.subst.same(start) {
+		// nothing new was learned
+		return true
+	}
+	return s.verify()
+}
+
+// Add the disequality constraint for the values
+func (s *Stream) disunify(u, v any) bool {
+	prefix, ok := s.prefix(u, v)
+	if !ok {
+		// the values can never be unified
+		return true
+	}
+	if len(prefix) == 0 {
+		// the values are already equal
+		return false
+	}
+	s.diseqs = append(s.diseqs, prefix)
+	return true
+}
+
+// Re-check the constraints after the stream was extended,
+// until no new bindings are made
+func (s *Stream) verify() bool {
+	for {
+		start := s.subst
+		if !s.verifyDisequalities() {
+			return false
+		}
+		if len(s.fdcs) > 0 || len(s.domains) > 0 {
+			if !s.propagate() {
+				return false
+			}
+		}
+		if s.subst.same(start) {
+			return true
+		}
+	}
+}
+
+// Re-check the disequality constraints, drop the ones that
+// can no longer be violated and simplify the rest
+func (s *Stream) verifyDisequalities() bool {
+	var acc []Prefix
+	for _, p := range s.diseqs {
+		prefix, ok := s.prefix(p.pairs()...)
+		if !ok {
+			continue
+		}
+		if len(prefix) == 0 {
+			return false
+		}
+		acc = append(acc, prefix)
+	}
+	s.diseqs = acc
+	return true
+}
+
+// Find the bindings that are missing for the pairs of values to be unified,
+// return false if they cannot be unified
+func (s Stream) prefix(pairs ...any) (Prefix, bool) {
+	var trail []KeyVal
+	t := s.fork()
+	t.trail = &trail
+	for i := 0; i < len(pairs); i += 2 {
+		if !t.unify(pairs[i], pairs[i+1]) {
+			return nil, false
+		}
+	}
+	return Prefix(trail), true
+}
+
+func (p Prefix) pairs() []any {
+	var acc []any
+	for _, kv := range p {
+		acc = append(acc, kv.key, kv.val)
+	}
+	return acc
+}
+
+func (p Prefix) String() string {
+	var acc []string
+	for _, kv := range p {
+		acc = append(acc, kv.String())
+	}
+	return fmt.Sprintf("(%s)", strings.Join(acc, " "))
+}
+
+// The type constraints, with the names used when reifying them
+var typeChecks = []struct {
+	name  string
+	check func(any) bool
+}{
+	{"sym", func(v any) bool { _, ok := v.(types.Symbol); return ok }},
+	{"num", types.IsNumber},
+	{"str", func(v any) bool { _, ok := v.(string); return ok }},
+}
+
+func typeCheck(kind string) func(any) bool {
+	for _, t := range typeChecks {
+		if t.name == kind {
+			return t.check
+		}
+	}
+	return nil
+}
+
+// Constrain the value to be of the kind, for unbound variables
+// the check is delayed until they are bound
+func (s *Stream) addType(v any, kind string) bool {
+	switch v := s.walk(v).(type) {
+	case types.Variable:
+		for _, kv := range s.typed {
+			if kv.key == v {
+				return kv.val == kind
+			}
+		}
+		s.typed = append(s.typed, KeyVal{v, kind})
+		return true
+	default:
+		return typeCheck(kind)(v)
+	}
+}
+
+// Constrain the tag to not occur in the value, for unbound variables
+// the check is delayed until they are bound
+func (s *Stream) absent(tag, v any) bool {
+	return s.absentIn(tag, v, nil)
+}
+
+// Check the value skipping the cycles, that lead back to the variables on the path
+func (s *Stream) absentIn(tag, v any, path *varPath) bool {
+	for {
+		x, ok := v.(types.Variable)
+		if !ok {
+			break
+		}
+		if path.contains(x) {
+			return true
+		}
+		val, ok := s.get(x)
+		if !ok || val == x {
+			for _, kv := range s.absents {
+				if kv.key == x && kv.val == tag {
+					return true
+				}
+			}
+			s.absents = append(s.absents, KeyVal{x, tag})
+			return true
+		}
+		path = s.extendPath(path, x)
+		v = val
+	}
+	switch v := v.(type) {
+	case types.Pair:
+		return s.absentIn(tag, v.This, path) && s.absentIn(tag, v.Next, path)
+	case *types.Vector:
+		if path.contains(v) {
+			return true
+		}
+		path = &varPath{v, path}
+		return !v.Any(func(e any) bool { return !s.absentIn(tag, e, path) })
+	default:
+		return !types.Eqv(v, tag)
+	}
+}
+
+// Check the delayed constraints on the variable that was bound to the value
+func (s *Stream) checkAttributes(u types.Variable, v any) bool {
+	for _, kv := range s.typed {
+		if kv.key == u && !s.addType(v, kv.val.(string)) {
+			return false
+		}
+	}
+	for _, kv := range s.absents {
+		if kv.key == u && !s.absent(kv.val, v) {
+			return false
+		}
+	}
+	return s.checkDomain(u, v)
+}
+
+// Transform the constraints to the `(=/= ((_.0 a)) ...)`, `(sym _.0 ...)`,
+// `(absento (a _.0) ...)` etc forms, using the stream r that maps the variables
+// to their reified names, the constraints on the variables that are not
+// a part of the answer are dropped
+func (s Stream) reifyConstraints(r *Stream) []any {
+	var (
+		acc  []any
+		seen = make(map[string]bool)
+	)
+	for _, p := range s.diseqs {
+		c, ok := s.reifyPrefix(p, r)
+		if !ok {
+			continue
+		}
+		key := types.ToString(c)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		acc = append(acc, c)
+	}
+	var out []any
+	if len(acc) > 0 {
+		sortByString(acc)
+		out = append(out, types.Cons(types.Symbol("=/="), types.List(acc...)))
+	}
+	for _, t := range typeChecks {
+		acc = nil
+		for _, kv := range s.typed {
+			if kv.val != t.name {
+				continue
+			}
+			if v, ok := s.reifyTerm(kv.key, r); ok {
+				acc = append(acc, v)
+			}
+		}
+		if len(acc) > 0 {
+			sortByString(acc)
+			out = append(out, types.Cons(types.Symbol(t.name), types.List(acc...)))
+		}
+	}
+	acc = nil
+	for _, kv := range s.absents {
+		if v, ok := s.reifyTerm(kv.key, r); ok {
+			acc = append(acc, types.List(kv.val, v))
+		}
+	}
+	if len(acc) > 0 {
+		sortByString(acc)
+		out = append(out, types.Cons(types.Symbol("absento"), types.List(acc...)))
+	}
+	return out
+}
+
+// Reify the variable if it is still unbound and is a part of the answer
+func (s Stream) reifyTerm(v types.Variable, r *Stream) (any, bool) {
+	if s.walk(v) != v {
+		// the constraint was already checked when it was bound
+		return nil, false
+	}
+	val := r.deepWalk(v)
+	return val, !hasVariables(val)
+}
+
+func (s Stream) reifyPrefix(p Prefix, r *Stream) (any, bool) {
+	var acc []any
+	for _, kv := range p {
+		key := r.deepWalk(s.deepWalk(kv.key))
+		val := r.deepWalk(s.deepWalk(kv.val))
+		if hasVariables(key) || hasVariables(val) {
+			return nil, false
+		}
+		if a, ok := key.(types.Free); ok {
+			if b, ok := val.(types.Free); ok && b < a {
+				key, val = val, key
+			}
+		}
+		acc = append(acc, types.List(key, val))
+	}
+	sortByString(acc)
+	return types.List(acc...), true
+}
+
+// Check if the value contains any non-reified variables
+func hasVariables(v any) bool {
+	switch v := v.(type) {
+	case types.Variable:
+		return true
+	case types.Pair:
+		return v.Any(hasVariables)
+	case *types.Vector:
+		return v.Any(hasVariables)
+	case types.Labelled:
+		return hasVariables(v.Value)
+	default:
+		return false
+	}
+}
+
+func sortByString(vals []any) {
+	sort.SliceStable(vals, func(i, j int) bool {
+		return types.ToString(vals[i]) < types.ToString(vals[j])
+	})
+}
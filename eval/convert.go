@@ -0,0 +1,361 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+// FromGo converts the Go value to the Scheme value. The slices and arrays become lists,
+// the maps become association lists `((key . value) ...)` sorted by the keys, and
+// the structs become association lists of their exported fields, named by the `kanren`
+// tag, or by the field name when there is no tag. The string keys and the field names
+// are converted to symbols. The *big.Int and *big.Rat values are copied, and the unsigned
+// integers that do not fit into int become big integers. The variables and the Scheme
+// values are not changed, but the vectors and the bytevectors become constant, as they
+// can be used by the goals. The cyclic values, and the values like functions or channels,
+// cannot be converted.
+//
+//	type Person struct {
+//		Name string `kanren:"name"`
+//		Age  int    `kanren:"age"`
+//	}
+//	FromGo(Person{"Alice", 42}) // ((name . "Alice") (age . 42))
+func FromGo(val any) (any, error) {
+	return fromGo(reflect.ValueOf(val))
+}
+
+// The pointer, slice, or map that is being converted
+type reference struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// ToGo converts the Scheme value to the Go value pointed by ptr, it is the inverse of FromGo.
+// The numbers that do not fit into the type, like the rationals converted to integers, are errors.
+func ToGo(val any, ptr any) error {
+	p := reflect.ValueOf(ptr)
+	if p.Kind() != reflect.Pointer || p.IsNil() {
+		return fmt.Errorf("%v is not a pointer", ptr)
+	}
+	v, err := toGo(val, p.Type().Elem())
+	if err != nil {
+		return err
+	}
+	p.Elem().Set(v)
+	return nil
+}
+
+func fromGo(v reflect.Value) (any, error) {
+	return fromGoIn(v, nil)
+}
+
+// The references on the path to the converted value are recorded,
+// so reaching one of them again means that the value is cyclic
+func fromGoIn(v reflect.Value, path map[reference]bool) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	switch val := v.Interface().(type) {
+	case types.Pair, *types.Vector, *types.Bytevector, types.Symbol, types.Variable, types.Free, Goal:
+		return types.Freeze(val), nil
+	case types.Char, Procedure, *Lambda, *builtin:
+		return val, nil
+	case *big.Int, *big.Rat:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return types.FromBig(val), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if v.IsNil() || v.Kind() != reflect.Pointer && v.Len() == 0 {
+			break
+		}
+		ref := reference{v.Pointer(), 0, v.Type()}
+		if v.Kind() != reflect.Pointer {
+			ref.len = v.Len()
+		}
+		if path[ref] {
+			return nil, CyclicValue
+		}
+		if path == nil {
+			path = make(map[reference]bool)
+		}
+		path[ref] = true
+		defer delete(path, ref)
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return fromGoIn(v.Elem(), path)
+	case reflect.Slice, reflect.Array:
+		var acc []any
+		for i := range v.Len() {
+			e, err := fromGoIn(v.Index(i), path)
+			if err != nil {
+				return nil, err
+			}
+			acc = append(acc, e)
+		}
+		return types.List(acc...), nil
+	case reflect.Map:
+		var acc []any
+		for _, k := range v.MapKeys() {
+			key, err := fromGoKey(k, path)
+			if err != nil {
+				return nil, err
+			}
+			val, err := fromGoIn(v.MapIndex(k), path)
+			if err != nil {
+				return nil, err
+			}
+			acc = append(acc, types.Pair{This: key, Next: val})
+		}
+		sort.Slice(acc, func(i, j int) bool {
+			return types.ToString(acc[i].(types.Pair).This) < types.ToString(acc[j].(types.Pair).This)
+		})
+		return types.List(acc...), nil
+	case reflect.Struct:
+		var acc []any
+		for i, f := range fields(v.Type()) {
+			if f == "" {
+				continue
+			}
+			val, err := fromGoIn(v.Field(i), path)
+			if err != nil {
+				return nil, err
+			}
+			acc = append(acc, types.Pair{This: types.Symbol(f), Next: val})
+		}
+		return types.List(acc...), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+	if v.CanUint() && v.Uint() > math.MaxInt {
+		return new(big.Int).SetUint64(v.Uint()), nil
+	}
+	if isInteger(v.Kind()) {
+		return int(v.Convert(reflect.TypeFor[int]()).Int()), nil
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.String:
+		return v.Interface(), nil
+	}
+	return nil, UnsupportedType{v.Type()}
+}
+
+// The string keys of the maps are converted to symbols
+func fromGoKey(k reflect.Value, path map[reference]bool) (any, error) {
+	if k.Kind() == reflect.String {
+		return types.Symbol(k.String()), nil
+	}
+	return fromGoIn(k, path)
+}
+
+// The names of the fields of the struct, empty for the skipped fields
+func fields(t reflect.Type) []string {
+	var acc []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("kanren"); ok {
+			name = tag
+		}
+		if !f.IsExported() || name == "-" {
+			name = ""
+		}
+		acc = append(acc, name)
+	}
+	return acc
+}
+
+// Convert the Scheme value to the Go type
+func toGo(val any, t reflect.Type) (reflect.Value, error) {
+	if val == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Slice, reflect.Pointer, reflect.Map:
+			return reflect.Zero(t), nil
+		}
+		return reflect.Value{}, WrongArg{val}
+	}
+	if b, ok := val.(*types.Bytevector); ok && t == reflect.TypeFor[[]byte]() {
+		return reflect.ValueOf(slices.Clone(b.Bytes)), nil
+	}
+	if types.IsNumber(val) && (isNumber(t.Kind()) || t == bigIntType || t == bigRatType) {
+		return toGoNumber(val, t)
+	}
+	v := reflect.ValueOf(val)
+	switch {
+	case v.Type().AssignableTo(t):
+		return v, nil
+	case isNumber(v.Kind()) && isInteger(t.Kind()) && !isInteger(v.Kind()):
+		// the inexact numbers are not truncated
+		return reflect.Value{}, WrongArg{val}
+	case isNumber(v.Kind()) && isNumber(t.Kind()):
+		return v.Convert(t), nil
+	case v.Kind() == reflect.String && t.Kind() == reflect.String:
+		// the symbols and the strings
+		return v.Convert(t), nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		e, err := toGo(val, t.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(e)
+		return p, nil
+	case reflect.Slice:
+		acc := reflect.MakeSlice(t, 0, 0)
+		err := forEachElem(val, func(elem any) error {
+			e, err := toGo(elem, t.Elem())
+			if err != nil {
+				return err
+			}
+			acc = reflect.Append(acc, e)
+			return nil
+		})
+		return acc, err
+	case reflect.Array:
+		acc := reflect.New(t).Elem()
+		i := 0
+		err := forEachElem(val, func(elem any) error {
+			if i >= t.Len() {
+				return WrongArg{val}
+			}
+			e, err := toGo(elem, t.Elem())
+			if err != nil {
+				return err
+			}
+			acc.Index(i).Set(e)
+			i++
+			return nil
+		})
+		if err == nil && i != t.Len() {
+			err = WrongArg{val}
+		}
+		return acc, err
+	case reflect.Map:
+		acc := reflect.MakeMap(t)
+		err := forEachElem(val, func(elem any) error {
+			p, ok := elem.(types.Pair)
+			if !ok {
+				return WrongArg{elem}
+			}
+			k, err := toGo(p.This, t.Key())
+			if err != nil {
+				return err
+			}
+			e, err := toGo(p.Next, t.Elem())
+			if err != nil {
+				return err
+			}
+			acc.SetMapIndex(k, e)
+			return nil
+		})
+		return acc, err
+	case reflect.Struct:
+		acc := reflect.New(t).Elem()
+		index := make(map[types.Symbol]int)
+		for i, f := range fields(t) {
+			if f != "" {
+				index[types.Symbol(f)] = i
+			}
+		}
+		err := forEachElem(val, func(elem any) error {
+			p, ok := elem.(types.Pair)
+			if !ok {
+				return WrongArg{elem}
+			}
+			name, ok := p.This.(types.Symbol)
+			if !ok {
+				return InvalidName{p.This}
+			}
+			i, ok := index[name]
+			if !ok {
+				return fmt.Errorf("%v has no field %v", t, name)
+			}
+			e, err := toGo(p.Next, t.Field(i).Type)
+			if err != nil {
+				return err
+			}
+			acc.Field(i).Set(e)
+			return nil
+		})
+		return acc, err
+	}
+	return reflect.Value{}, WrongArg{val}
+}
+
+var (
+	bigIntType = reflect.TypeFor[*big.Int]()
+	bigRatType = reflect.TypeFor[*big.Rat]()
+)
+
+// Convert the Scheme number to the Go number, the big numbers are copied,
+// and the values that do not fit into the type are not truncated
+func toGoNumber(val any, t reflect.Type) (reflect.Value, error) {
+	switch t {
+	case bigIntType:
+		if n, ok := types.ToBigInt(val); ok {
+			return reflect.ValueOf(n), nil
+		}
+		return reflect.Value{}, WrongArg{val}
+	case bigRatType:
+		if r, ok := types.ToBigRat(val); ok {
+			return reflect.ValueOf(r), nil
+		}
+		return reflect.Value{}, WrongArg{val}
+	}
+	acc := reflect.New(t).Elem()
+	if acc.CanFloat() {
+		acc.SetFloat(types.ToInexact(val))
+		return acc, nil
+	}
+	n, ok := types.ToBigInt(val)
+	switch {
+	case !ok:
+		// the inexact numbers and the rationals
+	case acc.CanInt() && n.IsInt64() && !acc.OverflowInt(n.Int64()):
+		acc.SetInt(n.Int64())
+		return acc, nil
+	case acc.CanUint() && n.IsUint64() && !acc.OverflowUint(n.Uint64()):
+		acc.SetUint(n.Uint64())
+		return acc, nil
+	}
+	return reflect.Value{}, WrongArg{val}
+}
+
+// Apply the function to the elements of the proper list
+func forEachElem(val any, fn func(any) error) error {
+	head := val
+	for head != nil {
+		p, ok := head.(types.Pair)
+		if !ok {
+			return NonList{val}
+		}
+		if err := fn(p.This); err != nil {
+			return err
+		}
+		head = p.Next
+	}
+	return nil
+}
+
+func isInteger(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Uint64
+}
+
+func isNumber(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Float64
+}
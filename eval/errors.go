@@ -3,6 +3,7 @@ package eval
 import (
 	"errors"
 	"fmt"
+	"reflect"
 
 	"github.com/twolodzko/kanren/types"
 )
@@ -10,6 +11,10 @@ import (
 var ArityError = errors.New("wrong number of arguments")
 var SyntaxError = errors.New("invalid syntax")
 var TypeError = errors.New("invalid type")
+var DivisionByZero = errors.New("division by zero")
+var NumberTooLarge = errors.New("number too large")
+var SizeTooLarge = errors.New("size too large")
+var CyclicValue = errors.New("cannot convert the cyclic value")
 
 type WrongArg struct {
 	Val any
@@ -19,6 +24,14 @@ func (e WrongArg) Error() string {
 	return fmt.Sprintf("invalid argument: %s", types.ToString(e.Val))
 }
 
+type NotAProcedure struct {
+	Val any
+}
+
+func (e NotAProcedure) Error() string {
+	return fmt.Sprintf("%s is not a procedure", types.ToString(e.Val))
+}
+
 type NonList struct {
 	Val any
 }
@@ -35,6 +48,14 @@ func (e NaN) Error() string {
 	return fmt.Sprintf("%s is not a number", types.ToString(e.Val))
 }
 
+type NotAnInteger struct {
+	Val any
+}
+
+func (e NotAnInteger) Error() string {
+	return fmt.Sprintf("%s is not an integer", types.ToString(e.Val))
+}
+
 type InvalidName struct {
 	Val any
 }
@@ -42,3 +63,67 @@ type InvalidName struct {
 func (e InvalidName) Error() string {
 	return fmt.Sprintf("%s is not a valid name", types.ToString(e.Val))
 }
+
+// The Go value that has no Scheme counterpart, like a function or a channel
+type UnsupportedType struct {
+	Type reflect.Type
+}
+
+func (e UnsupportedType) Error() string {
+	return fmt.Sprintf("cannot convert the value of type %v", e.Type)
+}
+
+// The constant value, like the literal, cannot be changed
+type ConstantError struct {
+	Val any
+}
+
+func (e ConstantError) Error() string {
+	return fmt.Sprintf("cannot change the constant %s", types.ToString(e.Val))
+}
+
+// The finite domain constraint cannot be checked, because its variables have no domains
+type DomainError struct {
+	Constraint any
+}
+
+func (e DomainError) Error() string {
+	return fmt.Sprintf("no domain for the variables in %s", types.ToString(e.Constraint))
+}
+
+// The arithmetic operation failed, the cause, like DivisionByZero, is wrapped
+type ArithmeticError struct {
+	Op   string
+	Args []any
+	Err  error
+}
+
+func (e ArithmeticError) Error() string {
+	expr := types.Cons(types.Symbol(e.Op), types.List(e.Args...))
+	return fmt.Sprintf("%v in %s", e.Err, types.ToString(expr))
+}
+
+func (e ArithmeticError) Unwrap() error {
+	return e.Err
+}
+
+// The Go panic that was recovered during the evaluation
+type RuntimeError struct {
+	Val any
+}
+
+func (e RuntimeError) Error() string {
+	return fmt.Sprintf("recovered from panic: %v", e.Val)
+}
+
+func (e RuntimeError) Unwrap() error {
+	err, _ := e.Val.(error)
+	return err
+}
+
+// Turn the panic into RuntimeError, it needs to be deferred
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		*err = RuntimeError{r}
+	}
+}
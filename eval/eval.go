@@ -8,40 +8,150 @@ import (
 	"github.com/twolodzko/kanren/types"
 )
 
+// Print the debugging information to stdout, it is used
+// for the environments not owned by an Interpreter
 var Debug = false
 
+// Use the occurs check in unification, when disabled
+// the unification is unsound, but faster
+var OccursCheck = true
+
 type (
-	tco  = func(any, *envir.Env) (any, *envir.Env, error)
-	proc = func(any, *envir.Env) (any, error)
+	tco = func(any, *envir.Env) (any, *envir.Env, error)
+	// Special form, it receives the unevaluated arguments
+	// and the env where it was called
+	Syntax = func(any, *envir.Env) (any, error)
 )
 
+// Builtin procedure, it receives the already evaluated arguments
+type Procedure func(args []any) (any, error)
+
+// The builtin procedure or special form bound to the name in the default environment,
+// registered from Go, or the tabled relation, the name is used when it is printed,
+// e.g. `#<procedure car>`
+type builtin struct {
+	name string
+	fn   any
+}
+
+func (b *builtin) String() string {
+	if _, ok := b.fn.(Procedure); ok {
+		return fmt.Sprintf("#<procedure %s>", b.name)
+	}
+	return fmt.Sprintf("#<syntax %s>", b.name)
+}
+
+// The Go function of the builtin, other values are returned unchanged
+func unwrap(val any) any {
+	if b, ok := val.(*builtin); ok {
+		return b.fn
+	}
+	return val
+}
+
 func Eval(sexpr any, env *envir.Env) (any, error) {
+	st := stateOf(env)
+	if st.depth == 0 {
+		return evalTop(sexpr, env, st)
+	}
+	return evalNested(sexpr, env, st)
+}
+
+// The top-level expression has its own budget, and the panics raised
+// during its evaluation are recovered as the errors
+func evalTop(sexpr any, env *envir.Env, st *evalState) (result any, err error) {
+	defer recoverError(&err)
+	defer func() { st.depth = 0 }()
+	if st.budget == nil {
+		defer st.use(defaultBudget(env))()
+	}
+	st.maxDepth = maxDepth(env)
+	return evalNested(sexpr, env, st)
+}
+
+func evalNested(sexpr any, env *envir.Env, st *evalState) (any, error) {
+	if st.maxDepth > 0 && st.depth >= st.maxDepth {
+		return nil, ErrMaxDepth
+	}
+	// when it panics, the depth is reset by evalTop
+	st.depth++
+	val, err := evalLoop(sexpr, env, st)
+	st.depth--
+	return val, err
+}
+
+func evalLoop(sexpr any, env *envir.Env, st *evalState) (result any, err error) {
+	// the tail calls stay in the envs of the same owner
+	out := debugOut(env)
 	for {
-		if Debug {
-			fmt.Printf(" ↪ eval:  %v\n", types.ToString(sexpr))
-			fmt.Printf("   env:   %v\n", env)
+		if err := st.budget.step(); err != nil {
+			return nil, err
+		}
+		if out != nil {
+			fmt.Fprintf(out, " ↪ eval:  %v\n", types.ToString(sexpr))
+			fmt.Fprintf(out, "   env:   %v\n", env)
 		}
 
 		switch val := sexpr.(type) {
 		case types.Symbol:
-			return getSymbol(val, env)
+			return getSymbol(sexpr, env)
 		case types.Pair:
 			name := val.This
 			args := val.Next
 
-			callable, err := Eval(name, env)
+			var callable any
+			if _, ok := name.(types.Symbol); ok {
+				callable, err = getSymbol(name, env)
+			} else {
+				callable, err = Eval(name, env)
+			}
 			if err != nil {
 				return nil, err
 			}
 
-			switch fn := callable.(type) {
+			switch fn := unwrap(callable).(type) {
 			case tco:
 				sexpr, env, err = fn(args, env)
 				if err != nil {
 					return nil, err
 				}
-			case proc:
+			case Syntax:
 				return fn(args, env)
+			case Procedure:
+				vals, err := evalArgs(args, env)
+				if err != nil {
+					return nil, err
+				}
+				val, err := fn(vals)
+				call, ok := val.(tailCall)
+				if err != nil || !ok {
+					return val, err
+				}
+				// the lambda called by apply is evaluated in place of the call
+				lambda, ok := call.fn.(*Lambda)
+				if !ok {
+					return call.apply(env)
+				}
+				sexpr, env, err = lambda.tailCall(call.args)
+				if err != nil {
+					return nil, err
+				}
+			case *Lambda:
+				if fn.goal != nil {
+					return fn.callGoal(args, env)
+				}
+				sexpr, env, err = fn.call(args, env)
+				if err != nil {
+					return nil, err
+				}
+			case goalForm:
+				return fn.eval(args, env)
+			case *Macro:
+				// the expansion is evaluated in place of the form
+				sexpr, err = fn.expandIn(val, env)
+				if err != nil {
+					return nil, err
+				}
 			default:
 				return nil, fmt.Errorf("%v is not callable", types.ToString(fn))
 			}
@@ -56,10 +166,13 @@ func Eval(sexpr any, env *envir.Env) (any, error) {
 func getSymbol(sexpr any, env *envir.Env) (any, error) {
 	switch val := sexpr.(type) {
 	case types.Symbol:
-		if val, ok := env.Get(val); ok {
-			return val, nil
+		if v, ok := lookup(val, env); ok {
+			if _, ok := v.(unassigned); ok {
+				return nil, fmt.Errorf("variable %v is used before its definition", original(val))
+			}
+			return v, nil
 		}
-		return nil, fmt.Errorf("unbound variable %v", val)
+		return nil, fmt.Errorf("unbound variable %v", original(val))
 	default:
 		return val, nil
 	}
@@ -1,8 +1,16 @@
 package eval
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/twolodzko/kanren/parser"
 	"github.com/twolodzko/kanren/types"
@@ -127,6 +135,164 @@ func TestScheme(t *testing.T) {
 		{"(let ((x 5)) (let ((y 4)) (+ x y)))", "9"},
 		{"((lambda (x) (let ((y 2)) (+ x y))) 9)", "11"},
 		{"((car (list + - * /)) 2 2)", "4"},
+		{"((lambda args args))", "()"},
+		{"((lambda args args) 1 2 3)", "(1 2 3)"},
+		{"((lambda (a . rest) rest) 1)", "()"},
+		{"((lambda (a b . rest) (list a b rest)) 1 2 3 4)", "(1 2 (3 4))"},
+		{"(let ((f (lambda (x . xs) (cons xs x)))) (f 1 (+ 1 1) 3))", "((2 3) . 1)"},
+		{"(lambda (a . rest) a)", "(lambda (a . rest) a)"},
+		{"(let () (define (add a b) (+ a b)) (add 2 3))", "5"},
+		{"(let () (define (count . xs) (cond ((null? xs) 0) (else (+ 1 (count))))) (count 1 2 3))", "1"},
+		{"(let () (define (f) 'ok) (define g f) (g))", "ok"},
+		{"(let* ((x 1) (y (+ x 1))) (list x y))", "(1 2)"},
+		{"(let ((x 1)) (let* ((x 2) (y x)) y))", "2"},
+		{"(let ((x 1)) (let ((x 2) (y x)) y))", "1"},
+		{"(letrec ((ev? (lambda (n) (cond ((= n 0) #t) (else (od? (- n 1)))))) (od? (lambda (n) (cond ((= n 0) #f) (else (ev? (- n 1))))))) (ev? 100))", "#t"},
+		{"(letrec* ((a 1) (b (+ a 1))) b)", "2"},
+		{"(let* ((x 1) (f (lambda () x)) (x 2)) (f))", "1"},
+		{"(let ((x 1)) (let* () (define x 2) x))", "2"},
+		{"(let ((x 1)) (let* () (define x 2) 'ok) x)", "1"},
+		{"(let () (define (ev? n) (cond ((= n 0) #t) (else (od? (- n 1))))) (define (od? n) (cond ((= n 0) #f) (else (ev? (- n 1))))) (ev? 10))", "#t"},
+		{"(let loop ((i 0) (acc '())) (cond ((= i 3) acc) (else (loop (+ i 1) (cons i acc)))))", "(2 1 0)"},
+		{"(let loop ((i 100000)) (cond ((= i 0) 'done) (else (loop (- i 1)))))", "done"},
+		{"((lambda (n) (define (ev? n) (cond ((= n 0) #t) (else (od? (- n 1))))) (define (od? n) (cond ((= n 0) #f) (else (ev? (- n 1))))) (od? n)) 7)", "#t"},
+		{"(let ((x 'outer)) (let () (define x 'inner) x) x)", "outer"},
+		{"(let ((x 1)) (set! x (+ x 1)) x)", "2"},
+		{"(let ((x 1)) (let ((f (lambda () (set! x 5)))) (f) x))", "5"},
+		{"(let ((x 1)) (let ((x 2)) (set! x 3)) x)", "1"},
+		{"(begin)", "()"},
+		{"(begin 1 2 3)", "3"},
+		{"(when (> 2 1) 'a 'b)", "b"},
+		{"(when (< 2 1) 'a)", "()"},
+		{"(unless (< 2 1) 'a 'b)", "b"},
+		{"(unless (> 2 1) 'a)", "()"},
+		{"(case (+ 1 2) ((1 2) 'low) ((3 4) 'mid) (else 'high))", "mid"},
+		{"(case 'x ((a) 1) ((b) 2) (else 3))", "3"},
+		{"(case 'b ((a) 1) ((b) 2 'two))", "two"},
+		{"(case 'c ((a) 1))", "()"},
+		{"(do ((i 0 (+ i 1)) (acc '() (cons i acc))) ((= i 3) acc))", "(2 1 0)"},
+		{"(let ((n 0)) (do ((i 0 (+ i 1))) ((= i 4) n) (set! n (+ n i))))", "6"},
+		{"(do ((i 100000 (- i 1))) ((= i 0) 'done))", "done"},
+		{"(let loop ((i 100000)) (when (> i 0) (loop (- i 1))))", "()"},
+		{"(let loop ((i 100000)) (begin (unless (= i 0) (loop (- i 1)))))", "()"},
+		{"(let loop ((i 100000)) (case i ((0) 'done) (else (loop (- i 1)))))", "done"},
+		{"(let-syntax ((m (syntax-rules () ((_ a b ...) (list b ... a))))) (m 1 2 3))", "(2 3 1)"},
+		{"(let-syntax ((m (syntax-rules () ((_ (a . b)) 'b)))) (m (1 2 3)))", "(2 3)"},
+		{"(let-syntax ((m (syntax-rules () ((_ a) `(a ,a))))) (m (+ 1 2)))", "((+ 1 2) 3)"},
+		{"(let-syntax ((m (syntax-rules (=>) ((_ a => b) b) ((_ a b) a)))) (list (m 1 => 2) (m 1 2)))", "(2 1)"},
+		{"(let-syntax ((m (syntax-rules ::: () ((_ a :::) '(a ::: ...))))) (m 1 2))", "(1 2 ...)"},
+		{"(let ((x 1)) (let-syntax ((inc! (syntax-rules () ((_ v) (set! v (+ v 1)))))) (inc! x) x))", "2"},
+		{"(letrec-syntax ((ev? (syntax-rules () ((_) #t) ((_ x . r) (od? . r)))) (od? (syntax-rules () ((_) #f) ((_ x . r) (ev? . r))))) (ev? 1 2 3 4))", "#t"},
+		{"(let ((if list)) (let-syntax ((m (syntax-rules () ((_ a) (let ((t a)) (cons t t)))))) (let ((t 5) (cons list)) (m t))))", "(5 . 5)"},
+		{"(/ 7 2)", "7/2"},
+		{"(/ 6 3)", "2"},
+		{"(+ 1/2 1/3)", "5/6"},
+		{"(* 2/3 3/2)", "1"},
+		{"(+ 1 1.5)", "2.5"},
+		{"(* 1/2 2.0)", "1.0"},
+		{"(- 0.5)", "-0.5"},
+		{"(+ 9223372036854775807 1)", "9223372036854775808"},
+		{"(- (+ 9223372036854775807 1) 1)", "9223372036854775807"},
+		{"(* 4294967296 4294967296)", "18446744073709551616"},
+		{"(- -9223372036854775808)", "9223372036854775808"},
+		{"(= 1 1.0 2/2)", "#t"},
+		{"(< 1/3 0.34 1/2 1)", "#t"},
+		{"(> 10000000000000000000000 1.0)", "#t"},
+		{"(% 7 2)", "1"},
+		{"(% -7 2)", "-1"},
+		{"(% 7.0 2)", "1.0"},
+		{"(% 100000000000000000001 10)", "1"},
+		{"(exact->inexact 1/4)", "0.25"},
+		{"(exact->inexact 2)", "2.0"},
+		{"(floor 7/2)", "3"},
+		{"(floor -7/2)", "-4"},
+		{"(floor -1.5)", "-2.0"},
+		{"(floor 3)", "3"},
+		{"(sqrt 16)", "4"},
+		{"(sqrt 9/4)", "3/2"},
+		{"(sqrt 2.25)", "1.5"},
+		{"(sqrt 2)", "1.4142135623730951"},
+		{"(expt 2 100)", "1267650600228229401496703205376"},
+		{"(expt 2/3 2)", "4/9"},
+		{"(expt 2 -2)", "1/4"},
+		{"(expt 2.0 3)", "8.0"},
+		{"(expt 4 0.5)", "2.0"},
+		{"(expt 1 100000000000)", "1"},
+		{"(expt -1 100000000001)", "-1"},
+		{"(number->string 255 16)", "\"ff\""},
+		{"(number->string -7/2 2)", "\"-111/10\""},
+		{"(number->string 1e21)", "\"1e+21\""},
+		{"(/ 1.0 0.0)", "+inf.0"},
+		{"(/ 0 5)", "0"},
+		{"(/ 2)", "1/2"},
+		{"(/ 0.5)", "2.0"},
+		{"(/ 2/3)", "3/2"},
+		{`(string-length "héllo")`, "5"},
+		{`(string-ref "héllo" 1)`, `#\é`},
+		{`(substring "hello" 1 3)`, `"el"`},
+		{`(substring "hello" 2)`, `"llo"`},
+		{`(string-append "a" "bc" "")`, `"abc"`},
+		{`(string-append)`, `""`},
+		{`(string->list "ab")`, `(#\a #\b)`},
+		{`(list->string (list #\a #\space #\x41))`, `"a A"`},
+		{`(string->symbol "abc")`, "abc"},
+		{`(symbol->string 'abc)`, `"abc"`},
+		{`(string=? "a" "a" "a")`, "#t"},
+		{`(string=? "a" "b")`, "#f"},
+		{`(string<? "a" "b" "c")`, "#t"},
+		{`(string<? "b" "a")`, "#f"},
+		{`(string? "a")`, "#t"},
+		{`(string? 'a)`, "#f"},
+		{`(char? #\a)`, "#t"},
+		{`(char=? #\a #\a)`, "#t"},
+		{`(char<? #\a #\b)`, "#t"},
+		{`(char-alphabetic? #\a)`, "#t"},
+		{`(char-numeric? #\1)`, "#t"},
+		{`(char-whitespace? #\tab)`, "#t"},
+		{`(char-upper-case? #\a)`, "#f"},
+		{`(char-upcase #\λ)`, `#\Λ`},
+		{`(char-downcase #\A)`, `#\a`},
+		{`(char->integer #\A)`, "65"},
+		{`(integer->char 955)`, `#\λ`},
+		{`"a\tb\"c"`, `"a\tb\"c"`},
+		{"#(1 (2 3) x)", "#(1 (2 3) x)"},
+		{"(vector 1 (+ 1 1) 'x)", "#(1 2 x)"},
+		{"(make-vector 2 'a)", "#(a a)"},
+		{"(vector-length #(1 2 3))", "3"},
+		{"(vector-ref #(1 2 3) 1)", "2"},
+		{"(let ((v (make-vector 3 0))) (vector-set! v 1 'a) v)", "#(0 a 0)"},
+		{"(vector->list #(1 2 3))", "(1 2 3)"},
+		{"(list->vector '(1 2 3))", "#(1 2 3)"},
+		{"(vector? #())", "#t"},
+		{"(vector? '(1))", "#f"},
+		{"#u8(1 2 255)", "#u8(1 2 255)"},
+		{"(bytevector 1 2)", "#u8(1 2)"},
+		{"(make-bytevector 2 7)", "#u8(7 7)"},
+		{"(bytevector-length #u8(1 2 3))", "3"},
+		{"(bytevector-u8-ref #u8(1 2 3) 2)", "3"},
+		{"(let ((b (make-bytevector 2 0))) (bytevector-u8-set! b 1 255) b)", "#u8(0 255)"},
+		{"(bytevector-append #u8(1) #u8() #u8(2 3))", "#u8(1 2 3)"},
+		{`(string->utf8 "aλ")`, "#u8(97 206 187)"},
+		{"(utf8->string #u8(97 206 187))", `"aλ"`},
+		{"(bytevector? #u8())", "#t"},
+		{"(bytevector? #())", "#f"},
+		{"(= #u8(1 2) (bytevector 1 2))", "#t"},
+		{"`#(1 ,(+ 1 1) (a ,(car '(3))))", "#(1 2 (a 3))"},
+		{"(apply + '(1 2 3))", "6"},
+		{"(apply + 1 2 '(3 4))", "10"},
+		{"(apply car '((1 2)))", "1"},
+		{"(apply list '())", "()"},
+		{"(apply (lambda (a . rest) rest) 1 '(2 3))", "(2 3)"},
+		{"(apply apply (list cons '(1 2)))", "(1 . 2)"},
+		{"((lambda (g) (g + '(1 2))) apply)", "3"},
+		{"(let ((f +)) f)", "#<procedure +>"},
+		{"(let ((f cond)) f)", "#<syntax cond>"},
+		{"(apply + '())", "0"},
+		{"(+)", "0"},
+		{"(*)", "1"},
+		{"(let ((f car)) (f '(1 2)))", "1"},
+		{"(let () (define (map f l) (cond ((null? l) '()) (else (cons (f (car l)) (map f (cdr l)))))) (map car '((1 2) (3 4))))", "(1 3)"},
+		{"((lambda (op) (op 6 3)) -)", "3"},
 	}
 
 	for _, tt := range testCases {
@@ -181,6 +347,7 @@ func TestKanren(t *testing.T) {
 		{"(run #f (q) (conde (succeed (== q 1)) (succeed (== q 2)) (fail (== q 'wrong)) (succeed (== q 3)) ))", "(1 2 3)"},
 		{"(run* (q) (conde (succeed (== q 1)) (succeed (== q 2)) (fail (== q 'wrong)) (succeed (== q 3)) ))", "(1 2 3)"},
 		{"(run* (q) (conde ((== q 'ok))))", "(ok)"},
+		{"(run* (q) (conde ((conde ((== q 1)) ((== q 2)))) ((== q 3))))", "(3 1 2)"},
 		{"(run 1 (q) (== `(1 . ,q) '(1)))", "(())"},
 		{"(run 1 (q) (== '(1 2 3) `(1 2 3 . ,q)))", "(())"},
 		{"(run 1 (q) (fresh (a b) (== `(,a . ,b) '(1 . 2)) (== `(,a . ,b) q)))", "((1 . 2))"},
@@ -226,6 +393,83 @@ func TestKanren(t *testing.T) {
 			`,
 			"((b . c))",
 		},
+		{"(run* (q) (=/= q 'a))", "((_.0 (=/= ((_.0 a)))))"},
+		{"(run* (q) (=/= q 'a) (== q 'a))", "()"},
+		{"(run* (q) (== q 'a) (=/= q 'a))", "()"},
+		{"(run* (q) (=/= q 'a) (== q 'b))", "(b)"},
+		{"(run* (q) (=/= 1 2))", "(_.0)"},
+		{"(run* (q) (conde ((== q 1)) ((== q 2))) (=/= q 1))", "(2)"},
+		{"(run* (q) (fresh (x) (=/= x 1) (== q 2)))", "(2)"},
+		{"(run* (q) (fresh (x y) (=/= x y) (== q (list x y))))", "(((_.0 _.1) (=/= ((_.0 _.1)))))"},
+		{"(run* (q) (fresh (x y) (=/= x y) (== x y)))", "()"},
+		{"(run* (q) (fresh (x y) (=/= (list x y) '(1 2)) (== x 1) (== q (list x y))))", "(((1 _.0) (=/= ((_.0 2)))))"},
+		{"(run* (q) (fresh (x y) (=/= (list x y) '(1 2)) (== x 1) (== y 2)))", "()"},
+		{"(run* (q) (fresh (x y) (=/= (list x y) '(1 2)) (== x 3) (== q (list x y))))", "((3 _.0))"},
+		{"(run* (q) (=/= q 'a) (=/= q 'b))", "((_.0 (=/= ((_.0 a)) ((_.0 b)))))"},
+		{"(run* (q) (symbolo q))", "((_.0 (sym _.0)))"},
+		{"(run* (q) (symbolo q) (== q 'a))", "(a)"},
+		{"(run* (q) (symbolo q) (== q 1))", "()"},
+		{"(run* (q) (== q 1) (symbolo q))", "()"},
+		{"(run* (q) (numbero q) (== q 1))", "(1)"},
+		{"(run* (q) (stringo q) (== q \"abc\"))", "(\"abc\")"},
+		{"(run* (q) (stringo q) (== q 'abc))", "()"},
+		{"(run* (q) (symbolo q) (numbero q))", "()"},
+		{"(run* (q) (symbolo q) (symbolo q))", "((_.0 (sym _.0)))"},
+		{"(run* (q) (fresh (x) (symbolo x) (== q x)))", "((_.0 (sym _.0)))"},
+		{"(run* (q) (fresh (x) (symbolo x) (numbero q) (== q x)))", "()"},
+		{"(run* (q) (fresh (x y) (symbolo x) (numbero y) (== q (list x y))))", "(((_.0 _.1) (sym _.0) (num _.1)))"},
+		{"(run* (q) (fresh (x) (symbolo x) (=/= x 1) (== q x)))", "((_.0 (sym _.0)))"},
+		{"(run* (q) (fresh (x) (symbolo x)))", "(_.0)"},
+		{"(run* (q) (absento 'a q))", "((_.0 (absento (a _.0))))"},
+		{"(run* (q) (absento 'a q) (== q 'a))", "()"},
+		{"(run* (q) (absento 'a q) (== q '(b (c a))))", "()"},
+		{"(run* (q) (absento 'a q) (== q '(b (c d))))", "((b (c d)))"},
+		{"(run* (q) (fresh (x) (absento 'a q) (== q (list 'b x))))", "(((b _.0) (absento (a _.0))))"},
+		{"(run* (q) (fresh (x) (absento 'a q) (== q (list 'b x)) (== x 'a)))", "()"},
+		{"(run* (q) (fresh (x y) (absento 'a x) (== x y) (== y '(a))))", "()"},
+		{"(run* (q) (infd q '(1 2 3)))", "(1 2 3)"},
+		{"(run* (q) (infd q (range 1 5)) (<fd q 3))", "(1 2)"},
+		{"(run* (q) (infd q (range 1 5)) (== q 7))", "()"},
+		{"(run* (q) (infd q (range 1 3)) (=/=fd q 2))", "(1 3)"},
+		{"(run* (q) (fresh (x y) (infd x y (range 1 10)) (+fd x y 10) (*fd x y 21) (== q (list x y))))", "((3 7) (7 3))"},
+		{"(run* (q) (fresh (x y) (infd x y (range 1 2)) (distinctfd (list x y)) (== q (list x y))))", "((1 2) (2 1))"},
+		{"(run* (q) (fresh (x y) (infd x y (range 1 3)) (<fd x y) (<fd y x)))", "()"},
+		{"(run 3 (q) (+fd 1 2 q))", "(3)"},
+		{"(run* (q) (+fd q 2 5))", "(3)"},
+		{"(run* (q) (*fd 3 q 12))", "(4)"},
+		{"(run* (q) (*fd 3 q 13))", "()"},
+		{"(run* (q) (fresh (x) (+fd x 1 q) (infd q '(5 6))))", "(5 6)"},
+		{"(run* (q) (fresh (x y) (infd x '(1 2)) (+fd x 1 y) (== q y)))", "(2 3)"},
+		{"(run* (q) (fresh () (== q 1)))", "(1)"},
+		{"(run* (q) (conda ((== q 1)) ((== q 2))))", "(1)"},
+		{"(run* (q) (conda (fail (== q 1)) ((== q 2))))", "(2)"},
+		{"(run* (q) (conda ((conde ((== q 1)) ((== q 2))) succeed) (else (== q 3))))", "(1 2)"},
+		{"(run* (q) (conda ((== q 1) fail) (else (== q 3))))", "()"},
+		{"(run* (q) (condu ((conde ((== q 1)) ((== q 2))) succeed) (else (== q 3))))", "(1)"},
+		{"(run* (q) (condu (fail) (else (== q 3))))", "(3)"},
+		{"(run* (q) (onceo (conde ((== q 1)) ((== q 2)))))", "(1)"},
+		{"(run* (q) (onceo fail))", "()"},
+		{"(run* (x y) (== x 1) (== y 2))", "((1 2))"},
+		{"(run* (x y) (conde ((== x 1)) ((== y 2))))", "((1 _.0) (_.0 2))"},
+		{"(run 1 (x y z) (== x y))", "((_.0 _.0 _.1))"},
+		{"(run* (x y) (=/= x y))", "(((_.0 _.1) (=/= ((_.0 _.1)))))"},
+		{"(run* (x y) (infd x y '(1 2)) (<fd x y))", "((1 2))"},
+		{"(run* (q) (== q (list q)))", "()"},
+		{"(run* (q) (fresh (x y) (== x (list y)) (== y (list x))))", "()"},
+		{"(run*/no-occurs (q) (== q (list q)))", "(#0=(#0#))"},
+		{"(run/no-occurs 1 (q) (fresh (x) (== q (cons 'a x)) (== x q)))", "((a . #0=(a . #0#)))"},
+		{"(run*/no-occurs (q) (fresh (x y) (== q (list x y)) (== x (list 1 x))))", "((#0=(1 #0#) _.0))"},
+		{"(run*/no-occurs (q) (fresh (x) (== x (list x)) (== q 1)))", "(1)"},
+		{"(run*/no-occurs (q) (fresh (x y) (== x (cons 'a x)) (== y (cons 'a y)) (== x y) (== q 1)))", "(1)"},
+		{"(run*/no-occurs (q) (fresh (x y) (== x (cons 'a x)) (== y (cons 'b y)) (== x y)))", "()"},
+		{"(run*/no-occurs (q) (fresh (x y z) (== x (cons 1 (cons 1 x))) (== y (cons 1 (cons 1 (cons 1 y)))) (== z (cons 1 y)) (== x z) (== q 1)))", "(1)"},
+		{"(run*/no-occurs (q) (fresh (x y) (== x (vector 1 x)) (== y (vector 1 y)) (== x y) (== q 1)))", "(1)"},
+		{"(run*/no-occurs (q) (fresh (x y) (== x (cons 'a x)) (== y (cons 'a y)) (=/= x y)))", "()"},
+		{"(run*/no-occurs (q) (fresh (x y) (== x (cons 'a x)) (== y (cons 'b y)) (=/= x y) (== q 1)))", "(1)"},
+		{"(run*/no-occurs (q) (== q (cons 'a q)) (absento 'b q))", "(#0=(a . #0#))"},
+		{"(run*/no-occurs (q) (== q (cons 'a q)) (absento 'a q))", "()"},
+		{"(run*/no-occurs (q) (absento 'b q) (== q (list 'a q)))", "(#0=(a #0#))"},
+		{"(run*/no-occurs (q) (fresh (x) (infd x '(1 2)) (== q (cons x q))))", "(#0=(1 . #0#) #0=(2 . #0#))"},
 		{
 			`
 			(run* (q)
@@ -236,6 +480,51 @@ func TestKanren(t *testing.T) {
 			`,
 			"(25)",
 		},
+		{"(run* (q) (fresh (list) (== list 1) (== q `(,list))))", "((1))"},
+		{"(run* (q) ((lambda (cons) (== q (list cons))) 'ok))", "((ok))"},
+		{"(run* (q) ((lambda (g) (conde (g) ((== q 2)))) (== q 1)))", "(1 2)"},
+		{"(run* (q) ((lambda args (== q args)) 1 2))", "((1 2))"},
+		{"(run* (q) ((lambda (x . rest) (== `(,x ,rest) q)) 1 2 3))", "((1 (2 3)))"},
+		{"(let () (define (caro p a) (fresh (d) (== (cons a d) p))) (run* (q) (caro '(1 2) q)))", "(1)"},
+		{"(run* (q) (begin (== q 1)))", "(1)"},
+		{"(run* (q) (matche q (a) (b) (c)))", "(a b c)"},
+		{"(run* (q) (matche (q 1) ((,x 1)) ((_ 2))))", "(_.0)"},
+		{"(run* (q) (matche '(1 2 3) ((,a . ,d) (== q `(,d ,a)))))", "(((2 3) 1))"},
+		{"(run* (q) (matche q ((,a ,a))))", "((_.0 _.0))"},
+		{"(run* (q) (matche q ((_ _))))", "((_.0 _.1))"},
+		{"(run* (q) (fresh (x) (== x 1) (matche q ((,x)))))", "((1))"},
+		{"(run* (q) ((lambdae (x y) ((,x ,x) (== q 'same)) ((_ _) (== q 'any))) 1 1))", "(same any)"},
+		{"(run* (q) ((λe (x) ((()) (== q 'empty)) (((_ . _)) (== q 'pair))) '(1)))", "(pair)"},
+		{"(let () (defrel (nullo x) (== x '())) (run* (q) (nullo q)))", "(())"},
+		{"(let () (defrel (listo . xs) (== xs '(1 2))) (run* (q) (listo q 2)))", "(1)"},
+		{"(let () (defrel (nullo x) (== x '())) (run* (q) (apply nullo (list q))))", "(())"},
+		{"(run* (q) (== q 1/2) (== q 2/4))", "(1/2)"},
+		{"(run* (q) (== q 1) (== q 1.0))", "()"},
+		{"(run* (q) (== q (expt 10 20)) (== q 100000000000000000000))", "(100000000000000000000)"},
+		{"(run* (q) (absento 100000000000000000000 q) (== q (list (expt 10 20))))", "()"},
+		{"(run* (q) (numbero q) (== q 2.5))", "(2.5)"},
+		{`(run* (q) (== (string->list "ab") (list #\a q)))`, `(#\b)`},
+		{"(run* (q) (== (vector 1 q) #(1 2)))", "(2)"},
+		{"(run* (q) (== `#(1 ,q) #(1 2)))", "(2)"},
+		{"(run* (q) (fresh (x) (== q (vector x x))))", "(#(_.0 _.0))"},
+		{"(run* (q) (== #(1 2) #(1 2 3)))", "()"},
+		{"(run* (q) (== #(1 2) '(1 2)))", "()"},
+		{"(run* (q) (fresh (x) (== q (vector x)) (== x q)))", "()"},
+		{"(run/no-occurs 1 (q) (== q (vector 1 q)))", "(#0=#(1 #0#))"},
+		{"(run* (q) (absento 'a q) (== q (vector 'b (list 'a))))", "()"},
+		{"(run* (q) (fresh (x y) (== q (vector x y)) (infd x y (range 1 3)) (<fd x y)))", "(#(1 2) #(1 3) #(2 3))"},
+		{"(run* (q) (== q (bytevector 1 2)) (== q #u8(1 2)))", "(#u8(1 2))"},
+		{"(run* (q) (conde ((== q #u8(1))) ((== q 'a))) (absento #u8(1) q))", "(a)"},
+		{"(run* (q) (matche q (#(1 ,x) (== x 2))) (== q #(1 2)))", "(#(1 2))"},
+		{"(run* (q) (matche q (#(_ _)) (#(a))))", "(#(_.0 _.1) #(a))"},
+		{"(let ((f (lambda (x) (== x 1)))) (run* (q) (apply f (list q))))", "(1)"},
+		{"(run* (q) (== q (apply list 1 '(2))))", "((1 2))"},
+		{"(run* (q) (apply == (list q 1)))", "(1)"},
+		{"(run* (q) (apply =/= q '(1)) (conde ((== q 1)) ((== q 2))))", "(2)"},
+		{"(run* (q) (apply infd q '((1 2))))", "(1 2)"},
+		{"(run* (q) (case 'b ((a) (== q 1)) ((b) (== q 2))))", "(2)"},
+		{"(run* (q) (let loop ((n 3)) (cond ((= n 0) (== q 'done)) (else (loop (- n 1))))))", "(done)"},
+		{"(run* (q) (let loop ((n 2)) (cond ((= n 0) fail) (else (conde ((== q n)) ((loop (- n 1))))))))", "(2 1)"},
 	}
 
 	for _, tt := range testCases {
@@ -259,6 +548,826 @@ func TestKanren(t *testing.T) {
 	}
 }
 
+func TestLambdaParams(t *testing.T) {
+	env := DefaultEnv()
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"((lambda (a b . rest) a) 1)", ArityError},
+		{"((lambda (a) a) 1 2)", ArityError},
+		{"(run* (q) ((lambda (a . rest) (== q a))))", ArityError},
+		{"(lambda (a 1) a)", InvalidName{1}},
+		{"(lambda 1 1)", NonList{1}},
+		{"(let () (defrel (f x) succeed) (f))", ArityError},
+		{"(run* (q) (matche (q q) ((1))))", ArityError},
+		{"(car '(1) '(2))", ArityError},
+		{"(apply +)", ArityError},
+		{"(sqrt -4)", WrongArg{-4}},
+		{"(number->string 10 3)", WrongArg{3}},
+		{"(floor 'a)", NaN{types.Symbol("a")}},
+		{"(/ 'a)", NaN{types.Symbol("a")}},
+		{`(substring "abc" 2 1)`, WrongArg{1}},
+		{`(string-ref "abc" 3)`, WrongArg{3}},
+		{`(string-length 'a)`, WrongArg{types.Symbol("a")}},
+		{`(char<? #\a "b")`, WrongArg{"b"}},
+		{"(vector-ref #(1 2) 2)", WrongArg{2}},
+		{"(vector-set! '(1 2) 0 'a)", WrongArg{types.List(1, 2)}},
+		{"(make-vector -1)", WrongArg{-1}},
+		{"(list->vector '(1 . 2))", NonList{types.Cons(1, 2)}},
+		{"(bytevector 1 256)", WrongArg{256}},
+		{"(bytevector-u8-ref #u8(1) 1)", WrongArg{1}},
+		{"(make-bytevector 1 'a)", WrongArg{types.Symbol("a")}},
+		{"(apply + 1)", NonList{1}},
+		{"(apply 5 '(1))", NotAProcedure{5}},
+	} {
+		_, _, err := EvalString(tt.input, env)
+		if err != tt.expected {
+			t.Errorf("for %v expected %v, got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	// the builtins are named in the errors, the names declared
+	// by letrec and the internal definitions cannot be read before
+	// they are defined
+	for _, tt := range []struct {
+		input    string
+		expected string
+	}{
+		{"(letrec ((a b) (b 1)) a)", "variable b is used before its definition"},
+		{"(run -1 (q) (== q 1))", "invalid argument: -1"},
+		{"(run 1.5 (q) (== q 1))", "1.5 is not an integer"},
+		{"(run #t (q) (== q 1))", "#t is not a number"},
+		{"(run-with-limit 0 1 (q) (== q 1))", "invalid argument: 0"},
+		{"(run-with-limit -5 1 (q) (== q 1))", "invalid argument: -5"},
+		{"(run-with-limit 2.5 1 (q) (== q 1))", "2.5 is not an integer"},
+		{"(define x 1) (define (h) (define y x) (define x 5) y) (h)", "variable x is used before its definition"},
+		{"(apply and '(1))", "#<syntax and> is not a procedure"},
+		{"(apply apply (list or '(1)))", "#<syntax or> is not a procedure"},
+		{"(vector-ref #(1) car)", "invalid argument: #<procedure car>"},
+	} {
+		_, _, err := EvalString(tt.input, env)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("for %v expected %v, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestInterleaving(t *testing.T) {
+	code := `
+	(define anyo
+		(lambda (g)
+			(conde
+				(g succeed)
+				(else (anyo g)))))
+	(define alwayso (anyo succeed))
+	(run 1 (q)
+		(conde
+			((== q 1) alwayso)
+			((== q 2)))
+		(== q 2))
+	`
+	result, _, err := EvalString(code, DefaultEnv())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the answer from the second branch is found even though
+	// the first one produces an infinite number of failures
+	expected := "(2)"
+	if got := types.ToString(result[len(result)-1]); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestTabling(t *testing.T) {
+	code := `
+	(define edgeo
+		(lambda (x y)
+			(conde
+				((== x 'a) (== y 'b))
+				((== x 'b) (== y 'a))
+				((== x 'b) (== y 'c)))))
+	(define patho
+		(tabled (x y)
+			(conde
+				((fresh (z) (patho x z) (edgeo z y)))
+				((edgeo x y)))))
+	(run* (q) (patho 'a q))
+	`
+	result, _, err := EvalString(code, DefaultEnv())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the left-recursive relation terminates on the cyclic graph
+	expected := "(b a c)"
+	if got := types.ToString(result[len(result)-1]); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestTablingConstraints(t *testing.T) {
+	code := `
+	(defrel (edgeo x y)
+		(conde
+			((== x 'a) (== y 'b))
+			((== x 'b) (== y 'c))
+			((== x 'c) (== y 'a))
+			((== x 'c) (== y 'd))))
+	(defrel-tabled (patho x y)
+		(conde
+			((edgeo x y))
+			((fresh (z) (edgeo x z) (patho z y)))))
+	`
+	for _, tt := range []struct {
+		input    string
+		expected string
+	}{
+		// the constrained producer does not hide the answers from the unconstrained consumer
+		{"(run* (q) (conde ((=/= q 'b) (patho 'a q)) ((patho 'a q))))", "(b c c a a d d)"},
+		{"(run* (q) (conde ((symbolo q) (=/= q 'a) (patho 'a q)) ((patho 'a q))))", "(b b c c d a d)"},
+		{"(run* (q) (conde ((absento 'c q) (patho 'a q)) ((patho 'a q))))", "(b b c a a d d)"},
+		{"(run* (q) (conde ((infd q '(1 2)) (patho 'a q)) ((patho 'a q))))", "(b c a d)"},
+		// the tabled relations are printed with their names
+		{"patho", "#<procedure patho>"},
+		{"(tabled (x) (== x 1))", "#<procedure tabled>"},
+	} {
+		env := DefaultEnv()
+		if _, _, err := EvalString(code, env); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		result, _, err := EvalString(tt.input, env)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := types.ToString(result[0]); got != tt.expected {
+			t.Errorf("for %s expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCompiledRelation(t *testing.T) {
+	code := `
+	(define nato
+		(lambda (n)
+			(conde
+				((== n 'z))
+				((fresh (m) (== n (list 's m)) (nato m))))))
+	(run 3 (q) (nato q))
+	`
+	env := DefaultEnv()
+	result, _, err := EvalString(code, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "(z (s z) (s (s z)))"
+	if got := types.ToString(result[len(result)-1]); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	// the body of the relation was compiled when the lambda was created
+	fn, _ := env.Get("nato")
+	if fn, ok := fn.(*Lambda); !ok || fn.goal == nil {
+		t.Errorf("expected compiled relation, got %v", fn)
+	}
+}
+
+func TestMacro(t *testing.T) {
+	code := `
+	(define-syntax conde*
+		(syntax-rules (else)
+			((_ (else g ...)) (fresh () g ...))
+			((_ (g ...) c ...) (conde ((fresh () g ...)) ((conde* c ...))))))
+	(define (smallo x)
+		(conde* ((== x 1)) ((== x 2)) (else (== x 3))))
+	(run* (q) (smallo q))
+	`
+	env := DefaultEnv()
+	result, _, err := EvalString(code, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "(1 2 3)"
+	if got := types.ToString(result[len(result)-1]); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	// the macro was expanded when the relation was compiled
+	fn, _ := env.Get("smallo")
+	if fn, ok := fn.(*Lambda); !ok || fn.goal == nil {
+		t.Errorf("expected compiled relation, got %v", fn)
+	}
+
+	for _, input := range []string{
+		"(define-syntax m (syntax-rules () ((_ a) a))) (m)",
+		"(define-syntax m (syntax-rules () ((_ a ...) a))) (m 1 2)",
+		"(define-syntax m (syntax-rules () ((_ a ...) (b ...)))) (m 1 2)",
+		"(define-syntax m (syntax-rules () ((_ (a ...) (b ...)) ((a b) ...)))) (m (1 2) (3))",
+		"(define-syntax m (syntax-rules () (_ 1)))",
+		"(define-syntax m 1)",
+	} {
+		if _, _, err := EvalString(input, DefaultEnv()); err == nil {
+			t.Errorf("for %v expected an error", input)
+		}
+	}
+
+	// the renamed symbols refer to the definitions
+	// of their own interpreter
+	macro := `
+	(define helper %d)
+	(define-syntax m (syntax-rules () ((_) helper)))
+	(let ((helper 0)) (m))
+	`
+	interpreters := []*Interpreter{NewInterpreter(Options{}), NewInterpreter(Options{})}
+	for i, in := range interpreters {
+		result, err := in.EvalString(fmt.Sprintf(macro, i+1))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := result[len(result)-1]; got != i+1 {
+			t.Errorf("expected %d, got %v", i+1, got)
+		}
+	}
+
+	// the local macros are not kept after the call
+	in := NewInterpreter(Options{})
+	if _, err := in.EvalString("(define (f x) (let-syntax ((m (syntax-rules () ((_) x)))) (m))) (f 1)"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	size := len(in.Env().Vars)
+	for i := 0; i < 3; i++ {
+		result, err := in.EvalString("(f 2)")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result[0] != 2 {
+			t.Errorf("expected 2, got %v", result[0])
+		}
+	}
+	if n := len(in.Env().Vars); n != size {
+		t.Errorf("expected %d global names, got %d", size, n)
+	}
+}
+
+func TestVectors(t *testing.T) {
+	for _, tt := range []struct {
+		input    string
+		expected string
+	}{
+		{"(define (f) (vector 1 2)) (vector-set! (f) 0 9) (f)", "#(1 2)"},
+		// the vectors bound by the goals are not changed when the original is changed
+		{"(define v (vector 1 2)) (run* (q) (conde ((== q v)) ((== q v) (project (q) (begin (vector-set! v 0 9) succeed)))))", "(#(1 2) #(1 2))"},
+		{"(define v (vector 1 2)) (define r (run* (q) (== q v))) (vector-set! v 0 9) r", "(#(1 2))"},
+		{"(define v (vector 1 2)) (define (g q) (== q v)) (run* (q) (g q)) (vector-set! v 0 9) (run* (q) (g q))", "(#(9 2))"},
+		{"(define l '(1 2)) (define (g q) (== q l)) (run* (q) (g q)) (define l '(3)) (run* (q) (g q))", "((3))"},
+		// the cyclic vectors are printed with the datum labels
+		{"(define v (vector 1 2)) (vector-set! v 0 v) v", "#0=#(#0# 2)"},
+		{"(define v (vector 1)) (vector-set! v 0 (list v)) (list v)", "(#0=#((#0#)))"},
+		{"(define v (vector 1 2)) (vector-set! v 0 v) (run* (q) (== q v))", "(#0=#(#0# 2))"},
+		{"(define v (vector 1 2)) (vector-set! v 0 v) (run* (q) (fresh (a) (== (vector a 2) v) (== q a)))", "(#0=#(#0# 2))"},
+		{"(define v (vector 1 2)) (vector-set! v 0 v) (run* (q) (== v v) (absento 3 v))", "(_.0)"},
+		{"(define v (vector 1 2)) (vector-set! v 0 v) (run* (q) (absento 2 v))", "()"},
+		{"(define v (vector 1 2)) (vector-set! v 0 v) (define w (vector 1 2)) (vector-set! w 0 w) (= v w)", "#t"},
+		// the vector patterns and templates of the macros
+		{"(define-syntax swap (syntax-rules () ((_ #(a b)) #(b a)))) (swap #(1 2))", "#(2 1)"},
+		{"(define-syntax firsts (syntax-rules () ((_ #(a b ...) ...) '(a ...)))) (firsts #(1 2 3) #(4))", "(1 4)"},
+		{"(define-syntax vec (syntax-rules () ((_ x ...) `#(,x ... end)))) (vec (+ 1 1) 3)", "#(2 3 end)"},
+		{"(define-syntax lit (syntax-rules () ((_) #(x y)))) (lit)", "#(x y)"},
+	} {
+		result, _, err := EvalString(tt.input, DefaultEnv())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := types.ToString(result[len(result)-1]); got != tt.expected {
+			t.Errorf("for %s expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// the literals and the vectors used by the goals cannot be changed
+	for _, input := range []string{
+		"(define (f) #(1 2)) (vector-set! (f) 0 9)",
+		"(bytevector-u8-set! #u8(1 2) 0 9)",
+		"(run* (q) (== q (vector 1 2)) (project (q) (begin (vector-set! q 0 9) succeed)))",
+	} {
+		var constErr ConstantError
+		if _, _, err := EvalString(input, DefaultEnv()); !errors.As(err, &constErr) {
+			t.Errorf("for %v expected the constant error, got %v", input, err)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	env := DefaultEnv()
+	code := `
+	(define nato
+		(lambda (n)
+			(conde
+				((== n 'z))
+				((fresh (m) (== n (list 's m)) (nato m))))))
+	`
+	if _, _, err := EvalString(code, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	q, err := QueryString("(q) (nato q)", env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the answers of the infinite relation are produced on demand
+	for _, expected := range []string{"z", "(s z)", "(s (s z))"} {
+		r, ok, err := q.Next()
+		if err != nil || !ok {
+			t.Fatalf("expected an answer, got %v, %v", ok, err)
+		}
+		if got := types.ToString(r); got != expected {
+			t.Errorf("expected %s, got %s", expected, got)
+		}
+	}
+	var acc []any
+	for r, err := range q.All() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		acc = append(acc, r)
+		if len(acc) == 2 {
+			break
+		}
+	}
+	expected := "((s (s (s z))) (s (s (s (s z)))))"
+	if got := types.ToString(types.List(acc...)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	q, err = QueryString("(x y) (conde ((== x 1)) ((== x 2))) (== y x)", env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	acc = nil
+	for r, err := range q.All() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		acc = append(acc, r)
+	}
+	expected = "((1 1) (2 2))"
+	if got := types.ToString(types.List(acc...)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if _, ok, _ := q.Next(); ok {
+		t.Errorf("expected the query to be exhausted")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	env := DefaultEnv()
+	code := `
+	(define nato
+		(lambda (n)
+			(conde
+				((== n 'z))
+				((fresh (m) (== n (list 's m)) (nato m))))))
+	`
+	if _, _, err := EvalString(code, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, _, err := EvalString("(run-with-limit 20 #f (q) (nato q))", env)
+	var limitErr LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrMaxSteps) {
+		t.Fatalf("expected the step limit error, got %v", err)
+	}
+	expected := "(z (s z) (s (s z)) (s (s (s z))))"
+	if got := types.ToString(types.List(limitErr.Answers...)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// the limit is not reached for the finite number of answers
+	result, _, err := EvalString("(run-with-limit 20 2 (q) (nato q))", env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = "(z (s z))"
+	if got := types.ToString(result[0]); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// the evaluation of the Scheme code is also charged against the limit
+	if _, _, err := EvalString("(define (loop) (loop))", env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _, err = EvalString("(run-with-limit 1000 #f (q) (project (q) (loop)))", env)
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrMaxSteps) {
+		t.Errorf("expected the step limit error, got %v", err)
+	}
+	for _, opts := range []Options{{MaxSteps: 1000}, {Timeout: 10 * time.Millisecond}} {
+		in := NewInterpreter(opts)
+		_, err := in.EvalString("(define (loop) (loop)) (loop)")
+		if !errors.Is(err, ErrMaxSteps) && !errors.Is(err, ErrTimeout) {
+			t.Errorf("expected the limit error for %+v, got %v", opts, err)
+		}
+		_, err = in.EvalString("(run* (q) (project (q) (loop)))")
+		if !errors.Is(err, ErrMaxSteps) && !errors.Is(err, ErrTimeout) {
+			t.Errorf("expected the limit error for %+v, got %v", opts, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q, err := NewQueryContext(ctx, types.List(types.List(types.Symbol("q")), types.List(types.Symbol("nato"), types.Symbol("q"))), env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok, err := q.Next(); !ok || err != nil {
+		t.Fatalf("expected an answer, got %v, %v", ok, err)
+	}
+	cancel()
+	for _, err = range q.All() {
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the query to be cancelled, got %v", err)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	in := NewInterpreter(Options{MaxDepth: 10000})
+	if err := in.Register("crash", func() int { panic("crash") }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tt := range []struct {
+		input    string
+		expected error
+	}{
+		{"(/ 1 0)", DivisionByZero},
+		{"(% 5 0)", DivisionByZero},
+		{"(/ 1.0 0)", DivisionByZero},
+		{"(expt 0 -1)", DivisionByZero},
+		{"(% 1/2 3)", TypeError},
+		{"(run* (q) (== q (/ 1 0)))", DivisionByZero},
+		{"(define (f) (+ 1 (f))) (f)", ErrMaxDepth},
+		{"(define-syntax m (syntax-rules () ((_) (+ 1 (m))))) (m)", ErrMaxDepth},
+		{"(expt 2 100000000000)", NumberTooLarge},
+		{"(expt 1/2 (- (expt 2 100)))", NumberTooLarge},
+		{"(define (sq x n) (cond ((= n 0) x) (else (sq (* x x) (- n 1))))) (sq 2 40)", NumberTooLarge},
+		{"(make-vector 10000000000000)", SizeTooLarge},
+		{"(make-bytevector 10000000000000 0)", SizeTooLarge},
+	} {
+		if _, err := in.EvalString(tt.input); !errors.Is(err, tt.expected) {
+			t.Errorf("for %v expected %v, got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	// the depth is limited also outside of the interpreter
+	MaxDepth = 10000
+	env := DefaultEnv()
+	env.Owner = nil
+	if _, _, err := EvalString("(define (f) (+ 1 (f))) (f)", env); !errors.Is(err, ErrMaxDepth) {
+		t.Errorf("expected %v, got %v", ErrMaxDepth, err)
+	}
+	MaxDepth = 0
+	// the tail calls through apply do not nest
+	if _, err := in.EvalString("(define (loop n) (cond ((= n 0) 'done) (else (apply loop (list (- n 1)))))) (loop 100000)"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	// the deep recursion is not limited by default
+	if _, _, err := EvalString("(define (build n acc) (cond ((= n 0) acc) (else (build (- n 1) (cons n acc))))) (define (len l) (cond ((null? l) 0) (else (+ 1 (len (cdr l)))))) (len (build 200000 (quote ())))", DefaultEnv()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	_, err := in.EvalString("(/ 2 (- 1 1))")
+	var arithErr ArithmeticError
+	if !errors.As(err, &arithErr) || arithErr.Op != "/" || !reflect.DeepEqual(arithErr.Args, []any{2, 0}) {
+		t.Errorf("expected the arithmetic error, got %v", err)
+	}
+	if _, err := in.EvalString("(list 1 (crash))"); !errors.As(err, new(RuntimeError)) {
+		t.Errorf("expected the runtime error, got %v", err)
+	}
+	// the constraints cannot be enumerated without the domains
+	for _, input := range []string{
+		"(run* (q) (<fd q 0))",
+		"(run* (q) (fresh (x) (<fd x 0) (== q 1)))",
+		"(run* (q) (*fd 0 q 0))",
+	} {
+		if _, err := in.EvalString(input); !errors.As(err, new(DomainError)) {
+			t.Errorf("for %v expected the domain error, got %v", input, err)
+		}
+	}
+	// the errors do not break the interpreter
+	result, err := in.EvalString("(+ 1 2)")
+	if err != nil || result[0] != 3 {
+		t.Errorf("expected 3, got %v (%v)", result, err)
+	}
+}
+
+func TestInterpreter(t *testing.T) {
+	var debug bytes.Buffer
+	interpreters := []*Interpreter{
+		NewInterpreter(Options{Debug: &debug}),
+		NewInterpreter(Options{NoOccursCheck: true}),
+	}
+	code := `
+	(define appendo
+		(lambda (l s out)
+			(conde
+				((== '() l) (== s out))
+				((fresh (a d res)
+					(== (cons a d) l)
+					(== (cons a res) out)
+					(appendo d s res))))))
+	(run* (x y) (appendo x y '(1 2 3)))
+	`
+	expected := "((() (1 2 3)) ((1) (2 3)) ((1 2) (3)) ((1 2 3) ()))"
+
+	// the interpreters have their own environments and settings, so they can run at the same time
+	var wg sync.WaitGroup
+	for _, in := range interpreters {
+		for range 4 {
+			wg.Go(func() {
+				result, err := in.EvalString(code)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				if got := in.ToString(result[len(result)-1]); got != expected {
+					t.Errorf("expected %s, got %s", expected, got)
+				}
+			})
+		}
+	}
+	wg.Wait()
+
+	if debug.Len() == 0 {
+		t.Errorf("expected the debugging information")
+	}
+	if _, ok := interpreters[1].Env().Get("appendo"); !ok {
+		t.Errorf("expected appendo to be defined")
+	}
+	if _, ok := DefaultEnv().Get("appendo"); ok {
+		t.Errorf("the environments should not be shared")
+	}
+
+	result, err := interpreters[0].EvalString("(run* (q) (fresh (x) (== x (list x)) (== q 1)))")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := types.ToString(result[0]); got != "()" {
+		t.Errorf("expected no answers with the occurs check, got %s", got)
+	}
+	result, err = interpreters[1].EvalString("(run* (q) (fresh (x) (== x (list x)) (== q 1)))")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := types.ToString(result[0]); got != "(1)" {
+		t.Errorf("expected an answer without the occurs check, got %s", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := NewInterpreter(Options{})
+	for name, fn := range map[string]any{
+		"add": func(a, b int) int { return a + b },
+		"sum": func(nums ...int) int {
+			var acc int
+			for _, n := range nums {
+				acc += n
+			}
+			return acc
+		},
+		"reverse": func(l []any) []any {
+			var acc []any
+			for i := len(l) - 1; i >= 0; i-- {
+				acc = append(acc, l[i])
+			}
+			return acc
+		},
+		"half":   func(x float64) float64 { return x / 2 },
+		"double": func(n *big.Int) *big.Int { return n.Lsh(n, 1) },
+		"safe-div": func(a, b int64) (int64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		},
+	} {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	// the relation between x and the numbers from lo to hi
+	in.RegisterGoal("betweeno", 3, func(s *Stream, args []any) (Answers, error) {
+		lo, ok := args[0].(int)
+		if !ok {
+			return nil, NaN{args[0]}
+		}
+		hi, ok := args[1].(int)
+		if !ok {
+			return nil, NaN{args[1]}
+		}
+		var from func(i int) Suspension
+		from = func(i int) Suspension {
+			if i > hi {
+				return nil
+			}
+			return func() (Answers, error) {
+				return Yield(s.Unify(args[2], i), from(i+1)), nil
+			}
+		}
+		return Yield(nil, from(lo)), nil
+	})
+	// the infinite relation for the natural numbers
+	in.RegisterGoal("nato", 1, func(s *Stream, args []any) (Answers, error) {
+		var from func(i int) Suspension
+		from = func(i int) Suspension {
+			return func() (Answers, error) {
+				return Yield(s.Unify(args[0], i), from(i+1)), nil
+			}
+		}
+		return Yield(nil, from(0)), nil
+	})
+	in.RegisterGoal("oneo", 1, func(s *Stream, args []any) (Answers, error) {
+		return s.Unify(args[0], 1), nil
+	})
+
+	var testCases = []struct {
+		input    string
+		expected string
+	}{
+		{"(add 2 3)", "5"},
+		{"(sum)", "0"},
+		{"(sum 1 2 3 (add 1 1))", "8"},
+		{"(reverse '(1 (2 3) a))", "(a (2 3) 1)"},
+		{"(safe-div 7 2)", "3"},
+		{"(half 1/2)", "0.25"},
+		{"(half (expt 2 70))", "5.902958103587057e+20"},
+		{"(double (expt 2 70))", "2361183241434822606848"},
+		{"(double 3)", "6"},
+		{"(run* (q) (betweeno 1 3 q))", "(1 2 3)"},
+		{"(run* (q) (fresh (x) (== x 2) (betweeno 1 3 x)))", "(_.0)"},
+		{"(run* (q) (betweeno 1 3 4))", "()"},
+		{"(run* (q) (fresh (lo) (== lo 2) (betweeno lo 3 q)))", "(2 3)"},
+		{"(run* (q) (betweeno 3 1 q))", "()"},
+		{"(run 3 (q) (nato q))", "(0 1 2)"},
+		{"(run 1 (q) (nato q) (== q 5))", "(5)"},
+		{"(run 3 (q) (conde ((nato q)) ((== q 'x))))", "(x 0 1)"},
+		{"(run* (q) (oneo q))", "(1)"},
+		{"(run* (q) (oneo 2))", "()"},
+	}
+	for _, tt := range testCases {
+		result, err := in.EvalString(tt.input)
+		if err != nil {
+			t.Errorf("for %v got an unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got := types.ToString(result[0]); got != tt.expected {
+			t.Errorf("for %v expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{
+		"(add 1)",
+		"(add 1 #t)",
+		"(safe-div 1 0)",
+		"(safe-div (expt 2 70) 1)",
+		"(double 1/2)",
+		"(betweeno 1 q)",
+	} {
+		if _, err := in.EvalString(input); err == nil {
+			t.Errorf("for %v expected an error", input)
+		}
+	}
+	if err := in.Register("pair", func() (int, int) { return 1, 2 }); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestConvert(t *testing.T) {
+	type Point struct {
+		X      int `kanren:"x"`
+		Y      int `kanren:"y"`
+		Hidden int `kanren:"-"`
+		Label  string
+	}
+	var testCases = []struct {
+		input    any
+		expected string
+	}{
+		{nil, "()"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{"abc", "\"abc\""},
+		{[]int{1, 2, 3}, "(1 2 3)"},
+		{[2][]string{{"a"}, nil}, "((\"a\") ())"},
+		{map[string]int{"b": 2, "a": 1}, "((a . 1) (b . 2))"},
+		{Point{1, 2, 3, "p"}, "((x . 1) (y . 2) (Label . \"p\"))"},
+		{&Point{X: 1}, "((x . 1) (y . 0) (Label . \"\"))"},
+		{[]any{types.Symbol("s"), true}, "(s #t)"},
+		{big.NewInt(5), "5"},
+		{new(big.Int).Lsh(big.NewInt(1), 70), "1180591620717411303424"},
+		{big.NewRat(2, 6), "1/3"},
+		{big.NewRat(4, 2), "2"},
+		{(*big.Int)(nil), "()"},
+		{uint64(math.MaxUint64), "18446744073709551615"},
+	}
+	for _, tt := range testCases {
+		result, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("for %v got an unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got := types.ToString(result); got != tt.expected {
+			t.Errorf("for %v expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	// the values converted back are the same
+	for _, input := range []any{
+		[]int{1, 2, 3},
+		map[string]int{"b": 2, "a": 1},
+		[]Point{{1, 2, 0, "p"}, {3, 4, 0, "q"}},
+		map[string][]bool{"x": {true, false}},
+	} {
+		val, err := FromGo(input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ptr := reflect.New(reflect.TypeOf(input))
+		if err := ToGo(val, ptr.Interface()); err != nil {
+			t.Fatalf("for %v got an unexpected error: %v", input, err)
+		}
+		if !reflect.DeepEqual(ptr.Elem().Interface(), input) {
+			t.Errorf("expected %v, got %v", input, ptr.Elem().Interface())
+		}
+	}
+
+	// the cyclic values and the values without the Scheme counterpart are errors
+	type Node struct {
+		Next *Node
+	}
+	node := &Node{}
+	node.Next = node
+	cycle := []any{nil}
+	cycle[0] = cycle
+	for _, input := range []any{node, cycle, map[string]any{"a": cycle}} {
+		if _, err := FromGo(input); err != CyclicValue {
+			t.Errorf("for the cyclic value expected an error, got %v", err)
+		}
+	}
+	shared := &Node{}
+	if _, err := FromGo([]*Node{shared, shared}); err != nil {
+		t.Errorf("for the shared pointer got an unexpected error: %v", err)
+	}
+	for _, input := range []any{func() {}, make(chan int), complex(1, 2)} {
+		if _, err := FromGo(input); err != (UnsupportedType{reflect.TypeOf(input)}) {
+			t.Errorf("for %T expected an error, got %v", input, err)
+		}
+	}
+	if result, _ := FromGo(types.Char('a')); result != types.Char('a') {
+		t.Errorf("expected the character, got %v", result)
+	}
+
+	var p Point
+	if err := ToGo(types.List(types.Pair{This: types.Symbol("z"), Next: 1}), &p); err == nil {
+		t.Errorf("expected an error for the unknown field")
+	}
+	var n int
+	if err := ToGo("abc", &n); err == nil {
+		t.Errorf("expected an error for the wrong type")
+	}
+	if err := ToGo(1.5, &n); err == nil {
+		t.Errorf("expected an error for the inexact number")
+	}
+	var ns []int
+	if err := ToGo(types.List("a", 1), &ns); err != (WrongArg{"a"}) {
+		t.Errorf("expected an error for the wrong type of the element, got %v", err)
+	}
+	// the numbers are converted without the loss of the exactness or the overflows
+	var f float64
+	if err := ToGo(big.NewRat(1, 4), &f); err != nil || f != 0.25 {
+		t.Errorf("expected 0.25, got %v, %v", f, err)
+	}
+	huge := new(big.Int).Lsh(big.NewInt(1), 70)
+	if err := ToGo(huge, &n); err == nil {
+		t.Errorf("expected an error for the overflow, got %v", n)
+	}
+	var b int8
+	if err := ToGo(200, &b); err == nil {
+		t.Errorf("expected an error for the overflow, got %v", b)
+	}
+	var u uint
+	if err := ToGo(-1, &u); err == nil {
+		t.Errorf("expected an error for the negative number, got %v", u)
+	}
+	var i *big.Int
+	if err := ToGo(huge, &i); err != nil || i.Cmp(huge) != 0 || i == huge {
+		t.Errorf("expected a copy of %v, got %v, %v", huge, i, err)
+	}
+	var r *big.Rat
+	if err := ToGo(3, &r); err != nil || r.Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("expected 3, got %v, %v", r, err)
+	}
+	var bs []byte
+	if err := ToGo(types.NewBytevector(1, 2), &bs); err != nil || !bytes.Equal(bs, []byte{1, 2}) {
+		t.Errorf("expected the bytes, got %v, %v", bs, err)
+	}
+}
+
 func TestWalk(t *testing.T) {
 	memory := NewStream()
 	x := types.NewVariable("x")
@@ -283,7 +1392,10 @@ func TestDeepWalk(t *testing.T) {
 		types.List(true, 1, 0),
 		2,
 	)
-	memory := Stream{[]KeyVal{{x, 0}, {y, 1}, {z, 2}}}
+	memory := NewStream()
+	memory.extend(x, 0)
+	memory.extend(y, 1)
+	memory.extend(z, 2)
 	result := memory.deepWalk(input)
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("expected: %v, got %v", expected, result)
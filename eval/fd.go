@@ -0,0 +1,658 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+// Finite domain of the variable, the values are sorted and unique
+type Domain []int
+
+func newDomain(vals []int) Domain {
+	d := slices.Clone(vals)
+	slices.Sort(d)
+	return slices.Compact(d)
+}
+
+// Transform a list of integers to domain
+func toDomain(v any) (Domain, error) {
+	var acc []int
+	switch p := v.(type) {
+	case types.Pair:
+		err := p.ForEach(func(val any) error {
+			n, ok := val.(int)
+			if !ok {
+				return NaN{val}
+			}
+			acc = append(acc, n)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	case nil:
+	default:
+		return nil, NonList{v}
+	}
+	return newDomain(acc), nil
+}
+
+func (d Domain) min() int {
+	return d[0]
+}
+
+func (d Domain) max() int {
+	return d[len(d)-1]
+}
+
+func (d Domain) contains(n int) bool {
+	_, ok := slices.BinarySearch(d, n)
+	return ok
+}
+
+func (d Domain) intersect(other Domain) Domain {
+	var acc Domain
+	for _, n := range d {
+		if other.contains(n) {
+			acc = append(acc, n)
+		}
+	}
+	return acc
+}
+
+// Keep only the values lo <= x <= hi
+func (d Domain) bounded(lo, hi int) Domain {
+	var acc Domain
+	for _, n := range d {
+		if lo <= n && n <= hi {
+			acc = append(acc, n)
+		}
+	}
+	return acc
+}
+
+func (d Domain) without(n int) Domain {
+	var acc Domain
+	for _, x := range d {
+		if x != n {
+			acc = append(acc, x)
+		}
+	}
+	return acc
+}
+
+func (d Domain) String() string {
+	var acc []string
+	for _, n := range d {
+		acc = append(acc, fmt.Sprintf("%d", n))
+	}
+	return fmt.Sprintf("{%s}", strings.Join(acc, " "))
+}
+
+// Arithmetic constraint on the integer values or variables with finite domains
+type fdConstraint struct {
+	op   string
+	args []any
+}
+
+func (c fdConstraint) String() string {
+	var acc []string
+	for _, a := range c.args {
+		acc = append(acc, types.ToString(a))
+	}
+	return fmt.Sprintf("(%s %s)", c.op, strings.Join(acc, " "))
+}
+
+// Get the domain of the variable
+func (s Stream) domain(v types.Variable) (Domain, bool) {
+	for i := len(s.domains) - 1; i >= 0; i-- {
+		if s.domains[i].key == v {
+			return s.domains[i].val.(Domain), true
+		}
+	}
+	return nil, false
+}
+
+// Get the domain of the value, integers have single-element domains
+func (s Stream) domainOf(v any) (Domain, bool) {
+	switch v := s.walk(v).(type) {
+	case types.Variable:
+		return s.domain(v)
+	case int:
+		return Domain{v}, true
+	default:
+		return nil, false
+	}
+}
+
+// The result of narrowing the domains, ok is false if any domain became empty
+type narrowing struct {
+	ok, changed bool
+}
+
+// Narrow the domain of the value
+func (s *Stream) restrict(v any, d Domain) narrowing {
+	switch v := s.walk(v).(type) {
+	case types.Variable:
+		old, ok := s.domain(v)
+		if ok {
+			d = old.intersect(d)
+		}
+		if len(d) == 0 {
+			return narrowing{false, false}
+		}
+		if ok && len(d) == len(old) {
+			return narrowing{true, false}
+		}
+		s.domains = append(s.domains, KeyVal{v, d})
+		return narrowing{true, true}
+	case int:
+		return narrowing{d.contains(v), false}
+	default:
+		return narrowing{false, false}
+	}
+}
+
+// Same as restrict, but only for the values that already have domains
+func (s *Stream) restrictBounds(v any, lo, hi int) narrowing {
+	d, ok := s.domainOf(v)
+	if !ok {
+		return narrowing{true, false}
+	}
+	return s.restrict(v, d.bounded(lo, hi))
+}
+
+// Check the domain of the variable that was bound to the value
+func (s *Stream) checkDomain(u types.Variable, v any) bool {
+	d, ok := s.domain(u)
+	if !ok {
+		return true
+	}
+	return s.restrict(v, d).ok
+}
+
+// Narrow the domains using the constraints until nothing changes anymore,
+// the variables with single-element domains are bound to their values
+func (s *Stream) propagate() bool {
+	for {
+		changed := false
+		for _, c := range s.fdcs {
+			r := s.propagateConstraint(c)
+			if !r.ok {
+				return false
+			}
+			changed = changed || r.changed
+		}
+		for _, kv := range s.domains {
+			d := kv.val.(Domain)
+			if len(d) != 1 || s.walk(kv.key) != kv.key {
+				continue
+			}
+			if !s.unify(kv.key, d[0]) {
+				return false
+			}
+			changed = true
+		}
+		if !changed {
+			return true
+		}
+	}
+}
+
+func (s *Stream) propagateConstraint(c fdConstraint) narrowing {
+	switch c.op {
+	case "=fd":
+		u, v := c.args[0], c.args[1]
+		du, uok := s.domainOf(u)
+		dv, vok := s.domainOf(v)
+		switch {
+		case uok && vok:
+			return all(s.restrict(u, dv), s.restrict(v, du))
+		case uok:
+			return s.restrict(v, du)
+		case vok:
+			return s.restrict(u, dv)
+		}
+	case "=/=fd":
+		u, v := c.args[0], c.args[1]
+		if n, ok := s.walk(u).(int); ok {
+			if d, ok := s.domainOf(v); ok {
+				return s.restrict(v, d.without(n))
+			}
+		}
+		if n, ok := s.walk(v).(int); ok {
+			if d, ok := s.domainOf(u); ok {
+				return s.restrict(u, d.without(n))
+			}
+		}
+	case "<=fd", "<fd":
+		var diff int
+		if c.op == "<fd" {
+			diff = 1
+		}
+		u, v := c.args[0], c.args[1]
+		du, uok := s.domainOf(u)
+		dv, vok := s.domainOf(v)
+		if !uok || !vok {
+			return narrowing{true, false}
+		}
+		return all(
+			s.restrictBounds(u, du.min(), dv.max()-diff),
+			s.restrictBounds(v, du.min()+diff, dv.max()),
+		)
+	case "+fd":
+		u, v, w := c.args[0], c.args[1], c.args[2]
+		du, uok := s.domainOf(u)
+		dv, vok := s.domainOf(v)
+		dw, wok := s.domainOf(w)
+		if !uok || !vok || !wok {
+			return s.solve(c)
+		}
+		return all(
+			s.restrictBounds(w, du.min()+dv.min(), du.max()+dv.max()),
+			s.restrictBounds(u, dw.min()-dv.max(), dw.max()-dv.min()),
+			s.restrictBounds(v, dw.min()-du.max(), dw.max()-du.min()),
+		)
+	case "*fd":
+		u, v, w := c.args[0], c.args[1], c.args[2]
+		du, uok := s.domainOf(u)
+		dv, vok := s.domainOf(v)
+		dw, wok := s.domainOf(w)
+		if !uok || !vok || !wok {
+			return s.solve(c)
+		}
+		if du.min() < 0 || dv.min() < 0 || dw.min() < 0 {
+			// the bounds are only valid for non-negative numbers, so
+			// the constraint is checked after the values are known
+			return s.checkGround(c)
+		}
+		return all(
+			s.restrictBounds(w, du.min()*dv.min(), du.max()*dv.max()),
+			s.restrictBounds(u, divCeil(dw.min(), dv.max()), divFloor(dw.max(), dv.min(), du.max())),
+			s.restrictBounds(v, divCeil(dw.min(), du.max()), divFloor(dw.max(), du.min(), dv.max())),
+		)
+	case "distinctfd":
+		l, ok := s.deepWalk(c.args[0]).(types.Pair)
+		if !ok {
+			return narrowing{true, false}
+		}
+		elems := l.Map(func(val any) any { return val })
+		if elems[len(elems)-1] != nil {
+			// not a proper list yet
+			return narrowing{true, false}
+		}
+		elems = elems[:len(elems)-1]
+		var acc []narrowing
+		for i, x := range elems {
+			n, ok := x.(int)
+			if !ok {
+				continue
+			}
+			for j, y := range elems {
+				if i == j {
+					continue
+				}
+				if m, ok := y.(int); ok {
+					if n == m {
+						return narrowing{false, false}
+					}
+					continue
+				}
+				if d, ok := s.domainOf(y); ok {
+					acc = append(acc, s.restrict(y, d.without(n)))
+				}
+			}
+		}
+		return all(acc...)
+	}
+	return s.checkGround(c)
+}
+
+// Find the value of the only unknown argument of the arithmetic constraint,
+// so the constraints also work for the variables without domains
+func (s *Stream) solve(c fdConstraint) narrowing {
+	var (
+		vals    = make([]int, len(c.args))
+		unknown = -1
+	)
+	for i, a := range c.args {
+		n, ok := s.walk(a).(int)
+		if ok {
+			vals[i] = n
+			continue
+		}
+		if unknown >= 0 {
+			return narrowing{true, false}
+		}
+		unknown = i
+	}
+	if unknown < 0 {
+		return s.checkGround(c)
+	}
+	var n int
+	switch {
+	case c.op == "+fd" && unknown == 2:
+		n = vals[0] + vals[1]
+	case c.op == "+fd":
+		n = vals[2] - vals[1-unknown]
+	case c.op == "*fd" && unknown == 2:
+		n = vals[0] * vals[1]
+	case c.op == "*fd":
+		d := vals[1-unknown]
+		if d == 0 {
+			// any value is the solution, or none of them
+			return narrowing{vals[2] == 0, false}
+		}
+		if vals[2]%d != 0 {
+			return narrowing{false, false}
+		}
+		n = vals[2] / d
+	default:
+		return narrowing{true, false}
+	}
+	return s.restrict(c.args[unknown], Domain{n})
+}
+
+// Check the constraint if all its arguments are known
+func (s Stream) checkGround(c fdConstraint) narrowing {
+	var vals []int
+	for _, a := range c.args {
+		n, ok := s.walk(a).(int)
+		if !ok {
+			return narrowing{true, false}
+		}
+		vals = append(vals, n)
+	}
+	switch c.op {
+	case "=fd":
+		return narrowing{vals[0] == vals[1], false}
+	case "=/=fd":
+		return narrowing{vals[0] != vals[1], false}
+	case "<fd":
+		return narrowing{vals[0] < vals[1], false}
+	case "<=fd":
+		return narrowing{vals[0] <= vals[1], false}
+	case "+fd":
+		return narrowing{vals[0]+vals[1] == vals[2], false}
+	case "*fd":
+		return narrowing{vals[0]*vals[1] == vals[2], false}
+	}
+	return narrowing{true, false}
+}
+
+// Combine the results of multiple restrict calls
+func all(results ...narrowing) narrowing {
+	acc := narrowing{true, false}
+	for _, r := range results {
+		if !r.ok {
+			return narrowing{false, false}
+		}
+		acc.changed = acc.changed || r.changed
+	}
+	return acc
+}
+
+func divCeil(a, b int) int {
+	if b == 0 {
+		return 0
+	}
+	return (a + b - 1) / b
+}
+
+// Divide a by b, or return the default value when b is zero
+func divFloor(a, b, def int) int {
+	if b == 0 {
+		return def
+	}
+	return a / b
+}
+
+// Collect the unbound variables that have domains, in the order of their occurrence
+func (s Stream) domainVars(v any, acc []types.Variable) []types.Variable {
+	for _, x := range s.freeVars(v, nil) {
+		if _, ok := s.domain(x); ok && !slices.Contains(acc, x) {
+			acc = append(acc, x)
+		}
+	}
+	return acc
+}
+
+// Collect the unbound variables, in the order of their occurrence
+func (s Stream) freeVars(v any, acc []types.Variable) []types.Variable {
+	return s.freeVarsIn(v, acc, nil)
+}
+
+// Walk the value skipping the cycles, that lead back to the variables on the path
+func (s Stream) freeVarsIn(v any, acc []types.Variable, path *varPath) []types.Variable {
+	for {
+		x, ok := v.(types.Variable)
+		if !ok {
+			break
+		}
+		if path.contains(x) {
+			return acc
+		}
+		val, ok := s.get(x)
+		if !ok || val == x {
+			if !slices.Contains(acc, x) {
+				acc = append(acc, x)
+			}
+			return acc
+		}
+		path = s.extendPath(path, x)
+		v = val
+	}
+	switch v := v.(type) {
+	case types.Pair:
+		acc = s.freeVarsIn(v.This, acc, path)
+		acc = s.freeVarsIn(v.Next, acc, path)
+	case *types.Vector:
+		if path.contains(v) {
+			return acc
+		}
+		path = &varPath{v, path}
+		for _, e := range v.Elems {
+			acc = s.freeVarsIn(e, acc, path)
+		}
+	case types.Labelled:
+		acc = s.freeVarsIn(v.Value, acc, path)
+	}
+	return acc
+}
+
+// Try all the values from the domains of the variables
+func label(vars []types.Variable, s *Stream) (Answers, error) {
+	for len(vars) > 0 && s.walk(vars[0]) != vars[0] {
+		vars = vars[1:]
+	}
+	if len(vars) == 0 {
+		return s, nil
+	}
+	d, _ := s.domain(vars[0])
+	return labelFrom(vars, d, s)
+}
+
+func labelFrom(vars []types.Variable, d Domain, s *Stream) (Answers, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	var (
+		a   Answers
+		err error
+	)
+	t := s.fork()
+	if t.unifyVerify(vars[0], d[0]) {
+		a, err = label(vars[1:], t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mappend(a, func() (Answers, error) {
+		return labelFrom(vars, d[1:], s)
+	})
+}
+
+// Label the finite domain variables before reifying the answer, all the
+// labellings are tried for the variables that are a part of the answer,
+// or are linked to it by the constraints, for the rest it is only checked
+// if any labelling exists
+type Labelling struct {
+	target any
+}
+
+func (g Labelling) Query(s *Stream) (Answers, error) {
+	if len(s.domains) == 0 && len(s.fdcs) == 0 {
+		return s, nil
+	}
+	a, err := label(s.domainVars(s.linkedVars(g.target), nil), s)
+	if err != nil {
+		return nil, err
+	}
+	return bindGoal(a, labelOnce{})
+}
+
+func (g Labelling) String() string {
+	return fmt.Sprintf("(label %v)", types.ToString(g.target))
+}
+
+// The unbound variables of the value and the variables that
+// are linked to them by the finite domain constraints
+func (s Stream) linkedVars(v any) any {
+	vars := s.freeVars(v, nil)
+	for {
+		n := len(vars)
+		for _, c := range s.fdcs {
+			args := types.List(c.args...)
+			if slices.ContainsFunc(s.freeVars(args, nil), func(x types.Variable) bool {
+				return slices.Contains(vars, x)
+			}) {
+				vars = s.freeVars(args, vars)
+			}
+		}
+		if len(vars) == n {
+			break
+		}
+	}
+	acc := make([]any, len(vars))
+	for i, x := range vars {
+		acc[i] = x
+	}
+	return types.List(acc...)
+}
+
+type labelOnce struct{}
+
+func (labelOnce) Query(s *Stream) (Answers, error) {
+	var vars []types.Variable
+	for _, kv := range s.domains {
+		vars = s.domainVars(kv.key, vars)
+	}
+	answers, err := take(1, func() (Answers, error) {
+		return label(vars, s)
+	})
+	if err != nil || len(answers) == 0 {
+		return nil, err
+	}
+	// after the labelling, only the variables without domains can be unbound
+	t := answers[0]
+	for _, c := range t.fdcs {
+		args := t.deepWalk(types.List(c.args...))
+		if hasVariables(args) {
+			return nil, DomainError{types.Cons(types.Symbol(c.op), args)}
+		}
+	}
+	// the labelling of the remaining variables is not a part of the answer
+	return s, nil
+}
+
+// Constrain the variables to the finite domain
+//
+//	(infd x y ... dom)
+type InFD struct {
+	vars []any
+	dom  Domain
+}
+
+func (g InFD) Query(s *Stream) (Answers, error) {
+	s = s.fork()
+	for _, v := range g.vars {
+		if !s.restrict(v, g.dom).ok {
+			return nil, nil
+		}
+	}
+	if s.verify() {
+		return s, nil
+	}
+	return nil, nil
+}
+
+func (g InFD) String() string {
+	var acc []string
+	for _, v := range g.vars {
+		acc = append(acc, types.ToString(v))
+	}
+	return fmt.Sprintf("(infd %s %v)", strings.Join(acc, " "), g.dom)
+}
+
+func newInFD(args []any) (Goal, error) {
+	last := len(args) - 1
+	d, err := toDomain(args[last])
+	if err != nil {
+		return nil, err
+	}
+	return InFD{args[:last], d}, nil
+}
+
+type FDGoal struct {
+	op   string
+	args []any
+}
+
+func (g FDGoal) Query(s *Stream) (Answers, error) {
+	s = s.fork()
+	s.fdcs = append(s.fdcs, fdConstraint{g.op, g.args})
+	if s.verify() {
+		return s, nil
+	}
+	return nil, nil
+}
+
+func (g FDGoal) String() string {
+	var acc []string
+	for _, a := range g.args {
+		acc = append(acc, types.ToString(a))
+	}
+	return fmt.Sprintf("(%s %s)", g.op, strings.Join(acc, " "))
+}
+
+func newFDGoal(op string, arity int) goalForm {
+	return newPrimitive(op, arity, func(args []any) (Goal, error) {
+		return FDGoal{op, args}, nil
+	})
+}
+
+// Create a list of integers lo, lo+1, ..., hi
+//
+//	(range lo hi)
+func intRange(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, ArityError
+	}
+	a, b := args[0], args[1]
+	lo, ok := a.(int)
+	if !ok {
+		return nil, NaN{a}
+	}
+	hi, ok := b.(int)
+	if !ok {
+		return nil, NaN{b}
+	}
+	var acc []any
+	for i := lo; i <= hi; i++ {
+		acc = append(acc, i)
+	}
+	return types.List(acc...), nil
+}
@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+// The constructors of the goals for the Go code, see the kanren package
+
+var (
+	Succeed Goal = ConstGoal{"succeed", true}
+	Fail    Goal = ConstGoal{"fail", false}
+)
+
+func NewUnify(u, v any) Goal {
+	return Unify{u, v}
+}
+
+func NewDisequality(u, v any) Goal {
+	return Disequality{u, v}
+}
+
+// Interleave the answers of the goals, as conde with a goal per branch
+func NewConde(goals ...Goal) Goal {
+	var b branches
+	for _, g := range goals {
+		b.exprs = append(b.exprs, types.List(g))
+		b.goals = append(b.goals, []goalCode{ready{g}})
+	}
+	return Conde{b}
+}
+
+// Conjunction of the goals
+func NewConj(goals ...Goal) Goal {
+	return conj(goals)
+}
+
+// Create the n new variables each time the goal is queried, and query the goal
+// returned by the function for them
+func NewFresh(n int, fn func(vars ...types.Variable) Goal) Goal {
+	return freshFunc{n, fn}
+}
+
+// The goal that is created only when it is queried, so the recursive
+// relations can be defined
+func NewLazy(fn func() Goal) Goal {
+	return lazy(fn)
+}
+
+// The goal that was already created
+type ready struct {
+	goal Goal
+}
+
+func (c ready) instantiate(*frame) (Goal, error) {
+	return c.goal, nil
+}
+
+type conj []Goal
+
+func (g conj) Query(s *Stream) (Answers, error) {
+	return queryAll(g, s)
+}
+
+func (g conj) String() string {
+	var acc []string
+	for _, x := range g {
+		acc = append(acc, fmt.Sprintf("%v", x))
+	}
+	return fmt.Sprintf("(conj %s)", strings.Join(acc, " "))
+}
+
+type freshFunc struct {
+	n  int
+	fn func(vars ...types.Variable) Goal
+}
+
+func (g freshFunc) Query(s *Stream) (Answers, error) {
+	return Suspension(func() (Answers, error) {
+		var vars []types.Variable
+		for i := range g.n {
+			vars = append(vars, types.NewVariable(fmt.Sprintf("x%d", i)))
+		}
+		return query(g.fn(vars...), s)
+	}), nil
+}
+
+func (g freshFunc) String() string {
+	return fmt.Sprintf("(fresh %d <func>)", g.n)
+}
+
+type lazy func() Goal
+
+func (g lazy) Query(s *Stream) (Answers, error) {
+	return Suspension(func() (Answers, error) {
+		return query(g(), s)
+	}), nil
+}
+
+func (g lazy) String() string {
+	return "(lazy <func>)"
+}
@@ -0,0 +1,181 @@
+package eval
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/parser"
+	"github.com/twolodzko/kanren/types"
+)
+
+// Options of the interpreter, the zero value uses the defaults
+type Options struct {
+	// The debugging information is written to it, when it is not nil
+	Debug io.Writer
+	// Print the variables in the pretty form
+	Pretty bool
+	// Unify without the occurs check
+	NoOccursCheck bool
+	// The limits for a single run, or a top-level expression, no limits when zero
+	Timeout  time.Duration
+	MaxSteps int
+	// The maximal depth of the nested evaluations, no limit when zero
+	MaxDepth int
+	// The outputs, os.Stdout and os.Stderr when nil
+	Stdout, Stderr io.Writer
+}
+
+// Interpreter with its own environment and options, so the interpreters
+// with different settings can be used in a single process. It is safe
+// to use from multiple goroutines, the calls to a single interpreter
+// are run one at a time.
+type Interpreter struct {
+	mu    sync.Mutex
+	env   *envir.Env
+	opts  Options
+	state evalState
+}
+
+func NewInterpreter(opts Options) *Interpreter {
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	in := &Interpreter{opts: opts}
+	in.env = DefaultEnv()
+	in.env.Owner = in
+	return in
+}
+
+// Evaluate the expression in the global environment of the interpreter
+func (in *Interpreter) Eval(sexpr any) (any, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return Eval(sexpr, in.env)
+}
+
+// Parse and evaluate the code, return the results of all the expressions
+func (in *Interpreter) EvalString(code string) ([]any, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	out, _, err := EvalString(code, in.env)
+	return out, err
+}
+
+// Evaluate the script
+func (in *Interpreter) Load(path string) ([]any, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return LoadEval(path, in.env)
+}
+
+// Compile the query, e.g. `(q) (== q 1)`, the answers are searched
+// while holding the lock of the interpreter
+func (in *Interpreter) Query(code string) (*Query, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	sexprs, err := parser.NewParser(code).Read()
+	if err != nil {
+		return nil, err
+	}
+	q, err := NewQuery(types.List(sexprs...), in.env)
+	if err != nil {
+		return nil, err
+	}
+	q.lock = &in.mu
+	return q, nil
+}
+
+// Register the Go function as a Scheme procedure, see Register
+func (in *Interpreter) Register(name string, fn any) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return Register(in.env, name, fn)
+}
+
+// Register the relation implemented in Go, see RegisterGoal
+func (in *Interpreter) RegisterGoal(name string, arity int, fn GoalFunc) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	RegisterGoal(in.env, name, arity, fn)
+}
+
+// The global environment of the interpreter, it should not be used
+// concurrently with the other methods
+func (in *Interpreter) Env() *envir.Env {
+	return in.env
+}
+
+// Format the value using the options of the interpreter
+func (in *Interpreter) ToString(val any) string {
+	return types.Format(val, in.opts.Pretty)
+}
+
+func (in *Interpreter) Stdout() io.Writer {
+	return in.opts.Stdout
+}
+
+func (in *Interpreter) Stderr() io.Writer {
+	return in.opts.Stderr
+}
+
+// The interpreter owning the environment, nil when
+// the environment was not created by the interpreter
+func owner(env *envir.Env) *Interpreter {
+	in, _ := env.Owner.(*Interpreter)
+	return in
+}
+
+// The state of the evaluation in the environment, the environments that were not
+// created by DefaultEnv or an Interpreter get their own state
+func stateOf(env *envir.Env) *evalState {
+	switch o := env.Owner.(type) {
+	case *Interpreter:
+		return &o.state
+	case *evalState:
+		return o
+	default:
+		st := &evalState{}
+		env.Owner = st
+		return st
+	}
+}
+
+// Writer for the debugging information, nil when not debugging,
+// the package settings are used outside of the interpreter
+func debugOut(env *envir.Env) io.Writer {
+	if in := owner(env); in != nil {
+		return in.opts.Debug
+	}
+	if Debug {
+		return os.Stdout
+	}
+	return nil
+}
+
+func occursCheck(env *envir.Env) bool {
+	if in := owner(env); in != nil {
+		return !in.opts.NoOccursCheck
+	}
+	return OccursCheck
+}
+
+// The limits for a single run
+func limits(env *envir.Env) (time.Duration, int) {
+	if in := owner(env); in != nil {
+		return in.opts.Timeout, in.opts.MaxSteps
+	}
+	return Timeout, MaxSteps
+}
+
+func maxDepth(env *envir.Env) int {
+	if in := owner(env); in != nil {
+		return in.opts.MaxDepth
+	}
+	return MaxDepth
+}
@@ -1,6 +1,7 @@
 package eval
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,128 +10,94 @@ import (
 )
 
 type Goal interface {
-	Query(*Stream) (bool, error)
-	Next() bool
-	Reset()
+	Query(*Stream) (Answers, error)
 }
 
 func run(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	if p.This == false {
-		// (run #f (x) ... ) -> (run* (x) ...)
-		return runAll(p.Next, env)
-	} else if p.Next == nil {
-		return nil, ArityError
-	}
-	local := envir.NewEnvFrom(env)
+	return runN(args, env, occursCheck(env))
+}
 
-	reps, ok := p.This.(int)
-	if !ok {
-		return nil, NaN{p.This}
-	}
+func runAll(args any, env *envir.Env) (any, error) {
+	return runGoals(-1, args, env, occursCheck(env), defaultBudget(env))
+}
 
-	p, ok = p.Next.(types.Pair)
-	if !ok {
-		return nil, WrongArg{p.Next}
-	}
-	b, ok := p.This.(types.Pair)
-	if !ok {
-		return nil, WrongArg{p.Next}
-	}
-	name, ok := b.This.(types.Symbol)
-	if !ok {
-		return nil, InvalidName{b.This}
-	}
-	target := types.NewVariable(string(name))
-	local.Set(name, target)
+// The `run/no-occurs` variant of run using the unsound unification
+func runNoOccurs(args any, env *envir.Env) (any, error) {
+	return runN(args, env, false)
+}
 
-	body, ok := p.Next.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	goals, err := extractGoals(body, local)
+// The `run*/no-occurs` variant of run* using the unsound unification
+func runAllNoOccurs(args any, env *envir.Env) (any, error) {
+	return runGoals(-1, args, env, false, defaultBudget(env))
+}
+
+func runN(args any, env *envir.Env, occursCheck bool) (any, error) {
+	n, rest, err := runCount(args)
 	if err != nil {
 		return nil, err
 	}
-
-	var acc []any
-	i := 0
-	for i < reps {
-		s := NewStream()
-		s.birthRecord(target)
-		ok, err := queryAll(goals, s)
-		if err != nil {
-			return nil, err
-		}
-		if ok {
-			r := s.reify(target)
-			if Debug {
-				fmt.Printf("  result: %v\n", types.ToString(r))
-			}
-			acc = append(acc, r)
-			i += 1
-		}
-		if !next(goals) {
-			if Debug {
-				fmt.Println("       ∎  final goal")
-			}
-			break
-		}
-	}
-	return types.List(acc...), nil
+	return runGoals(n, rest, env, occursCheck, defaultBudget(env))
 }
 
-func runAll(args any, env *envir.Env) (any, error) {
+// Extract the number of answers, for `#f` it is -1, and the rest of the arguments
+func runCount(args any) (int, any, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
-		return nil, SyntaxError
+		return 0, nil, SyntaxError
 	}
-	local := envir.NewEnvFrom(env)
-
-	binding, ok := p.This.(types.Pair)
-	if !ok || p.Next == nil {
-		return nil, WrongArg{p.This}
+	if p.This == false {
+		// (run #f (x) ... ) -> (run* (x) ...)
+		return -1, p.Next, nil
+	} else if p.Next == nil {
+		return 0, nil, ArityError
 	}
-	name, ok := binding.This.(types.Symbol)
-	if !ok {
-		return nil, InvalidName{binding.This}
+	reps, err := count(p.This)
+	if err != nil {
+		return 0, nil, err
 	}
-	target := types.NewVariable(string(name))
-	local.Set(name, target)
+	return reps, p.Next, nil
+}
 
-	body, ok := p.Next.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
+// The count cannot be negative, or the number that is not an integer
+func count(val any) (int, error) {
+	n, ok := val.(int)
+	switch {
+	case !types.IsNumber(val):
+		return 0, NaN{val}
+	case !ok:
+		return 0, NotAnInteger{val}
+	case n < 0:
+		return 0, WrongArg{val}
 	}
-	goals, err := extractGoals(body, local)
+	return n, nil
+}
+
+// The budget using the limits of the interpreter
+func defaultBudget(env *envir.Env) *budget {
+	timeout, maxSteps := limits(env)
+	return newBudget(context.Background(), timeout, maxSteps)
+}
+
+// Run the goals for the target variables `((x ...) g1 g2 ...)`,
+// collect at most n results, or all of them when n < 0
+func runGoals(n int, args any, env *envir.Env, occursCheck bool, b *budget) (any, error) {
+	q, err := newQuery(args, env, occursCheck, b)
 	if err != nil {
 		return nil, err
 	}
-
 	var acc []any
-	for {
-		s := NewStream()
-		s.birthRecord(target)
-		ok, err := queryAll(goals, s)
+	for n < 0 || len(acc) < n {
+		r, ok, err := q.Next()
+		if isLimit(err) {
+			return nil, LimitError{err, acc}
+		}
 		if err != nil {
 			return nil, err
 		}
-		if ok {
-			r := s.reify(target)
-			if Debug {
-				fmt.Printf("  result: %v\n", types.ToString(r))
-			}
-			acc = append(acc, r)
-		}
-		if !next(goals) {
-			if Debug {
-				fmt.Println("       ∎  final goal")
-			}
+		if !ok {
 			break
 		}
+		acc = append(acc, r)
 	}
 	return types.List(acc...), nil
 }
@@ -140,207 +107,368 @@ type ConstGoal struct {
 	value bool
 }
 
-func (g ConstGoal) Query(_ *Stream) (bool, error) {
-	return g.value, nil
-}
-
-func (g ConstGoal) Next() bool {
-	return false
+func (g ConstGoal) Query(s *Stream) (Answers, error) {
+	if g.value {
+		return s, nil
+	}
+	return nil, nil
 }
 
-func (g ConstGoal) Reset() {}
-
 func (g ConstGoal) String() string {
 	return g.name
 }
 
 type Unify struct {
 	u, v any
-	env  *envir.Env
 }
 
-func (g Unify) Query(s *Stream) (bool, error) {
-	u, err := Eval(g.u, g.env)
-	if err != nil {
-		return false, err
+func (g Unify) Query(s *Stream) (Answers, error) {
+	s = s.fork()
+	if s.unifyVerify(g.u, g.v) {
+		return s, nil
 	}
-	v, err := Eval(g.v, g.env)
-	if err != nil {
-		return false, err
+	return nil, nil
+}
+
+func (g Unify) String() string {
+	return fmt.Sprintf("(== %v %v)", types.ToString(g.u), types.ToString(g.v))
+}
+
+func newUnify(args []any) (Goal, error) {
+	return Unify{args[0], args[1]}, nil
+}
+
+type Disequality struct {
+	u, v any
+}
+
+func (g Disequality) Query(s *Stream) (Answers, error) {
+	s = s.fork()
+	if s.disunify(g.u, g.v) {
+		return s, nil
 	}
-	ok := s.unify(u, v)
-	return ok, nil
+	return nil, nil
 }
 
-func (g Unify) Next() bool {
-	return false
+func (g Disequality) String() string {
+	return fmt.Sprintf("(=/= %v %v)", types.ToString(g.u), types.ToString(g.v))
 }
 
-func (g Unify) Reset() {}
+func newDisequality(args []any) (Goal, error) {
+	return Disequality{args[0], args[1]}, nil
+}
 
-func (g Unify) String() string {
-	return fmt.Sprintf("(== %v %v)", types.ToString(g.u), types.ToString(g.v))
+type TypeConstraint struct {
+	name string
+	kind string
+	u    any
 }
 
-func newUnify(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
+func (g TypeConstraint) Query(s *Stream) (Answers, error) {
+	s = s.fork()
+	if s.addType(g.u, g.kind) {
+		return s, nil
 	}
-	first := p.This
-	p, ok = p.Next.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
+	return nil, nil
+}
+
+func (g TypeConstraint) String() string {
+	return fmt.Sprintf("(%s %v)", g.name, types.ToString(g.u))
+}
+
+func newTypeConstraint(name, kind string) goalForm {
+	return newPrimitive(name, 1, func(args []any) (Goal, error) {
+		return TypeConstraint{name, kind, args[0]}, nil
+	})
+}
+
+type Absento struct {
+	tag, u any
+}
+
+func (g Absento) Query(s *Stream) (Answers, error) {
+	tag := s.walk(g.tag)
+	switch tag.(type) {
+	case types.Variable, types.Pair:
+		return nil, WrongArg{tag}
 	}
-	if p.Next != nil {
-		return nil, ArityError
+	s = s.fork()
+	if s.absent(tag, g.u) {
+		return s, nil
 	}
-	return Unify{first, p.This, env}, nil
+	return nil, nil
 }
 
-type Fresh struct {
-	vars  []types.Variable
-	goals []Goal
+func (g Absento) String() string {
+	return fmt.Sprintf("(absento %v %v)", types.ToString(g.tag), types.ToString(g.u))
 }
 
-func (g *Fresh) Query(s *Stream) (bool, error) {
-	for _, v := range g.vars {
-		s.birthRecord(v)
-	}
-	return queryAll(g.goals, s)
+func newAbsento(args []any) (Goal, error) {
+	return Absento{args[0], args[1]}, nil
 }
 
-func (g *Fresh) Next() bool {
-	return next(g.goals)
+type Fresh struct {
+	vars  []types.Symbol
+	body  types.Pair
+	goals []goalCode
+	frame *frame
 }
 
-func (g *Fresh) Reset() {
-	for _, g := range g.goals {
-		g.Reset()
-	}
+func (g Fresh) Query(s *Stream) (Answers, error) {
+	return Suspension(func() (Answers, error) {
+		// new variables are created each time the goal is queried
+		var vals []any
+		for _, name := range g.vars {
+			vals = append(vals, types.NewVariable(string(name)))
+		}
+		goals, err := instantiateAll(g.goals, g.frame.child(g.vars, vals))
+		if err != nil {
+			return nil, err
+		}
+		return queryAll(goals, s)
+	}), nil
 }
 
 func (g Fresh) String() string {
-	var vars, goals []string
+	var vars []string
 	for _, v := range g.vars {
-		vars = append(vars, types.ToString(v))
-	}
-	for _, g := range g.goals {
-		goals = append(goals, fmt.Sprintf("%v", g))
+		vars = append(vars, string(v))
 	}
-	return fmt.Sprintf("(fresh (%s) %s)", strings.Join(vars, " "), strings.Join(goals, " "))
+	return fmt.Sprintf("(fresh (%s) %s)", strings.Join(vars, " "), g.body.ToString())
 }
 
-func newFresh(args any, env *envir.Env) (any, error) {
+type freshCode Fresh
+
+func (c freshCode) instantiate(f *frame) (Goal, error) {
+	g := Fresh(c)
+	g.frame = f
+	return g, nil
+}
+
+func compileFresh(args any, sc *scope, env *envir.Env) (goalCode, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
 		return nil, SyntaxError
 	}
-	local := envir.NewEnvFrom(env)
-	pair, ok := p.This.(types.Pair)
-	if !ok {
+	var vars []types.Symbol
+	switch pair := p.This.(type) {
+	case types.Pair:
+		var err error
+		vars, err = extractSymbols(pair)
+		if err != nil {
+			return nil, err
+		}
+	case nil:
+	default:
 		return nil, NonList{p.This}
 	}
-	names, err := extractSymbols(pair)
-	if err != nil {
-		return nil, err
-	}
-	var vars []types.Variable
-	for _, name := range names {
-		v := types.NewVariable(string(name))
-		local.Set(name, v)
-		vars = append(vars, v)
-	}
 	body, ok := p.Next.(types.Pair)
 	if !ok {
 		return nil, SyntaxError
 	}
-	goals, err := extractGoals(body, local)
+	goals, err := compileGoals(body, &scope{vars, sc}, env)
 	if err != nil {
 		return nil, err
 	}
-	return &Fresh{vars, goals}, nil
+	return freshCode{vars, body, goals, nil}, nil
+}
+
+// The branches of conde, conda, or condu
+type branches struct {
+	exprs []any
+	goals [][]goalCode
+	frame *frame
+}
+
+// Create the goals of the i-th branch
+func (b branches) branch(i int) ([]Goal, error) {
+	return instantiateAll(b.goals[i], b.frame)
+}
+
+func (b branches) String() string {
+	var acc []string
+	for _, e := range b.exprs {
+		acc = append(acc, fmt.Sprintf("%v", e))
+	}
+	return strings.Join(acc, " ")
 }
 
 type Conde struct {
-	branches []any
-	current  int
-	branch   []Goal
-	env      *envir.Env
+	branches
 }
 
-func (g *Conde) Query(s *Stream) (bool, error) {
-	start := s.len()
-	for g.current < len(g.branches) {
-		if err := g.EnsureBranch(); err != nil {
-			return false, err
-		}
-		ok, err := queryAll(g.branch, s)
-		if err != nil {
-			return false, err
-		}
-		if ok {
-			return true, nil
-		}
-		if !g.Next() {
-			break
-		}
-		// backtrack
-		s.keep(start)
+func (g Conde) Query(s *Stream) (Answers, error) {
+	return Suspension(func() (Answers, error) {
+		return g.queryFrom(0, s)
+	}), nil
+}
+
+// Interleave the results of the i-th and the following branches
+func (g Conde) queryFrom(i int, s *Stream) (Answers, error) {
+	goals, err := g.branch(i)
+	if err != nil {
+		return nil, err
 	}
-	if Debug {
-		fmt.Println("       ∎  final goal")
+	a, err := queryAll(goals, s)
+	if err != nil {
+		return nil, err
 	}
-	return false, nil
+	if i == len(g.goals)-1 {
+		return a, nil
+	}
+	return mplus(a, func() (Answers, error) {
+		return g.queryFrom(i+1, s)
+	})
 }
 
-func (g *Conde) Next() bool {
-	if next(g.branch) {
-		return true
-	}
-	g.current += 1
-	g.branch = nil
-	return g.current < len(g.branches)
+func (g Conde) String() string {
+	return fmt.Sprintf("(conde %v)", g.branches)
 }
 
-func (g *Conde) Reset() {
-	g.current = 0
-	g.branch = nil
+// Soft-cut: the answers of the first branch whose first goal (the question)
+// succeeds, with the remaining goals of the branch applied to all of them
+// (see Byrd, 2009)
+type Conda struct {
+	branches
 }
 
-func (g Conde) String() string {
-	var branches []string
-	for _, b := range g.branches {
-		branches = append(branches, fmt.Sprintf("%v", b))
+func (g Conda) Query(s *Stream) (Answers, error) {
+	return queryCommitted(g.branches, 0, s, false)
+}
+
+func (g Conda) String() string {
+	return fmt.Sprintf("(conda %v)", g.branches)
+}
+
+// Committed choice: like conda, but only the first answer of the question
+// is used (see Byrd, 2009)
+type Condu struct {
+	branches
+}
+
+func (g Condu) Query(s *Stream) (Answers, error) {
+	return queryCommitted(g.branches, 0, s, true)
+}
+
+func (g Condu) String() string {
+	return fmt.Sprintf("(condu %v)", g.branches)
+}
+
+// Compile the branches for the goal created by the function
+type condCode struct {
+	branches
+	make func(branches) Goal
+}
+
+func (c condCode) instantiate(f *frame) (Goal, error) {
+	b := c.branches
+	b.frame = f
+	return c.make(b), nil
+}
+
+func newCond(name string, make func(branches) Goal) goalForm {
+	return goalForm{name, func(args any, sc *scope, env *envir.Env) (goalCode, error) {
+		exprs, err := extractBranches(args)
+		if err != nil {
+			return nil, err
+		}
+		var acc [][]goalCode
+		for _, b := range exprs {
+			goals, err := compileBranch(b, sc, env)
+			if err != nil {
+				return nil, err
+			}
+			acc = append(acc, goals)
+		}
+		return condCode{branches{exprs, acc, nil}, make}, nil
+	}}
+}
+
+// `(onceo g)` is the same as `(condu (g))`
+func compileOnceo(args any, sc *scope, env *envir.Env) (goalCode, error) {
+	p, ok := args.(types.Pair)
+	if !ok || p.Next != nil {
+		return nil, ArityError
 	}
-	return fmt.Sprintf("(conde %s)", strings.Join(branches, " "))
+	goal, err := compileGoal(p.This, sc, env)
+	if err != nil {
+		return nil, err
+	}
+	return condCode{
+		branches{[]any{types.List(p.This)}, [][]goalCode{{goal}}, nil},
+		func(b branches) Goal { return Condu{b} },
+	}, nil
 }
 
-func (g *Conde) EnsureBranch() error {
-	if g.branch == nil {
-		return g.InitBranch()
+// Query the question of the i-th branch, commit to the branch if it succeeds,
+// otherwise move to the next branch
+func queryCommitted(b branches, i int, s *Stream, once bool) (Answers, error) {
+	if i >= len(b.goals) {
+		return nil, nil
+	}
+	goals, err := b.branch(i)
+	if err != nil {
+		return nil, err
+	}
+	if len(goals) == 0 {
+		return s, nil
+	}
+	a, err := query(goals[0], s)
+	if err != nil {
+		return nil, err
+	}
+	return commit(a, goals[1:], once, func() (Answers, error) {
+		return queryCommitted(b, i+1, s, once)
+	})
+}
+
+// Force the answers of the question until it is known if it succeeded,
+// if not, continue with the other branches (ifa and ifu in Byrd, 2009)
+func commit(a Answers, goals []Goal, once bool, other Suspension) (Answers, error) {
+	switch a := a.(type) {
+	case nil:
+		return other()
+	case Suspension:
+		return Suspension(func() (Answers, error) {
+			b, err := a()
+			if err != nil {
+				return nil, err
+			}
+			return commit(b, goals, once, other)
+		}), nil
+	case *Stream:
+		return queryAll(goals, a)
+	case Waiting:
+		return a.then(func(b Answers) (Answers, error) {
+			return commit(b, goals, once, other)
+		}), nil
+	case Choice:
+		if once {
+			return queryAll(goals, a.answer)
+		}
+		return bindAll(a, goals)
+	default:
+		return nil, fmt.Errorf("invalid stream: %v", a)
 	}
-	return nil
 }
 
-func (g *Conde) InitBranch() error {
-	p, ok := g.branches[g.current].(types.Pair)
+// Compile the goals of the branch, the leading `else` is ignored
+func compileBranch(branch any, sc *scope, env *envir.Env) ([]goalCode, error) {
+	p, ok := branch.(types.Pair)
 	if !ok {
-		return NonList{g.branches[g.current]}
+		return nil, NonList{branch}
 	}
-	if p.This == types.Symbol("else") {
+	if isKeyword(p.This, "else") {
 		// no-op: this is a syntactic sugar
 		p, ok = p.Next.(types.Pair)
 		if !ok {
-			return SyntaxError
+			return nil, SyntaxError
 		}
 	}
-	var err error
-	g.branch, err = extractGoals(p, g.env)
-	return err
+	return compileGoals(p, sc, env)
 }
 
-func newConde(args any, env *envir.Env) (any, error) {
+func extractBranches(args any) ([]any, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
 		return nil, SyntaxError
@@ -357,54 +485,54 @@ func newConde(args any, env *envir.Env) (any, error) {
 		branches = append(branches, p.This)
 		head = p.Next
 	}
-	return &Conde{branches, 0, nil, env}, nil
+	return branches, nil
 }
 
 type Project struct {
 	vars  []types.Symbol
-	goals []Goal
-	env   *envir.Env
+	vals  []term
+	body  types.Pair
+	goals []goalCode
+	frame *frame
 }
 
-func (g *Project) Query(s *Stream) (bool, error) {
-	for _, name := range g.vars {
-		val, ok := g.env.Get(name)
-		if !ok {
-			return false, fmt.Errorf("unbound variable %v", name)
+func (g Project) Query(s *Stream) (Answers, error) {
+	var vals []any
+	for _, t := range g.vals {
+		val, err := t.value(g.frame)
+		if err != nil {
+			return nil, err
 		}
-		val = s.deepWalk(val)
-		g.env.Set(name, val)
+		vals = append(vals, s.deepWalk(val))
 	}
-	return queryAll(g.goals, s)
-}
-
-func (g *Project) Next() bool {
-	return next(g.goals)
-}
-
-func (g *Project) Reset() {
-	for _, g := range g.goals {
-		g.Reset()
+	goals, err := instantiateAll(g.goals, g.frame.child(g.vars, vals))
+	if err != nil {
+		return nil, err
 	}
+	return queryAll(goals, s)
 }
 
 func (g Project) String() string {
-	var vars, goals []string
+	var vars []string
 	for _, v := range g.vars {
 		vars = append(vars, string(v))
 	}
-	for _, g := range g.goals {
-		goals = append(goals, fmt.Sprintf("%v", g))
-	}
-	return fmt.Sprintf("(project (%s) %s)", strings.Join(vars, " "), strings.Join(goals, " "))
+	return fmt.Sprintf("(project (%s) %s)", strings.Join(vars, " "), g.body.ToString())
+}
+
+type projectCode Project
+
+func (c projectCode) instantiate(f *frame) (Goal, error) {
+	g := Project(c)
+	g.frame = f
+	return g, nil
 }
 
-func newProject(args any, env *envir.Env) (any, error) {
+func compileProject(args any, sc *scope, env *envir.Env) (goalCode, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
 		return nil, SyntaxError
 	}
-	local := envir.NewEnvFrom(env)
 	pair, ok := p.This.(types.Pair)
 	if !ok {
 		return nil, NonList{p.This}
@@ -417,67 +545,51 @@ func newProject(args any, env *envir.Env) (any, error) {
 	if !ok {
 		return nil, SyntaxError
 	}
-	goals, err := extractGoals(body, local)
+	var vals []term
+	for _, name := range vars {
+		vals = append(vals, compileTerm(name, sc, env))
+	}
+	goals, err := compileGoals(body, &scope{vars, sc}, env)
 	if err != nil {
 		return nil, err
 	}
-	return &Project{vars, goals, local}, nil
+	return projectCode{vars, vals, body, goals, nil}, nil
 }
 
-func queryAll(goals []Goal, s *Stream) (bool, error) {
-	for _, g := range goals {
-		if Debug {
-			fmt.Printf(" ↪ query: %v\n", g)
-			fmt.Printf("   subst: %v\n", s)
-		}
-		ok, err := g.Query(s)
-		if Debug {
-			if ok {
-				fmt.Println("       ✔  success")
-			} else {
-				fmt.Println("       ✘  failure")
-			}
-		}
-		if !ok || err != nil {
-			return false, err
-		}
-	}
-	return true, nil
+// Conjunction of the goals, where each goal is applied
+// to the results of the previous one
+func queryAll(goals []Goal, s *Stream) (Answers, error) {
+	return bindAll(s, goals)
 }
 
-func next(goals []Goal) bool {
-	// advance the deepest goals first
-	for i := len(goals) - 1; i >= 0; i-- {
-		g := goals[i]
-		if g.Next() {
-			return true
-		} else {
-			g.Reset()
+// Apply the goals in sequence to every answer in the stream
+func bindAll(a Answers, goals []Goal) (Answers, error) {
+	for _, g := range goals {
+		var err error
+		a, err = bindGoal(a, g)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return false
+	return a, nil
 }
 
-func extractGoals(pair types.Pair, env *envir.Env) ([]Goal, error) {
-	var (
-		goals []Goal
-		head  any = pair
-	)
-	for head != nil {
-		p, ok := head.(types.Pair)
-		if !ok {
-			return nil, SyntaxError
-		}
-		v, err := Eval(p.This, env)
-		if err != nil {
-			return nil, err
-		}
-		g, ok := v.(Goal)
-		if !ok {
-			return nil, WrongArg{v}
+func query(g Goal, s *Stream) (Answers, error) {
+	if s.debug != nil {
+		fmt.Fprintf(s.debug, " ↪ query: %v\n", g)
+		fmt.Fprintf(s.debug, "   subst: %v\n", s)
+	}
+	if err := s.budget.step(); err != nil {
+		return nil, err
+	}
+	a, err := g.Query(s)
+	if s.debug != nil && err == nil {
+		switch a.(type) {
+		case nil:
+			fmt.Fprintln(s.debug, "       ✘  failure")
+		case *Stream, Choice:
+			fmt.Fprintln(s.debug, "       ✔  success")
 		}
-		goals = append(goals, g)
-		head = p.Next
 	}
-	return goals, nil
+	return a, err
 }
@@ -1,42 +1,195 @@
 package eval
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/twolodzko/kanren/envir"
 	"github.com/twolodzko/kanren/types"
 )
 
+// The function defined with `lambda`
+type Lambda struct {
+	vars []types.Symbol
+	// the name for the list of the remaining arguments, empty when there is none
+	rest types.Symbol
+	body any
+	env  *envir.Env
+	// the names defined inside the body
+	defines []types.Symbol
+	// compiled body of the relation, when the body is a goal
+	goal goalCode
+	// the name of the relation defined with `defrel`,
+	// the calls to it are delayed
+	rel types.Symbol
+}
+
 // Create `lambda` function
 //
 //	(lambda (args ...) body ...)
+//	(lambda (args ... . rest) body ...)
+//	(lambda args body ...)
 func newLambda(args any, env *envir.Env) (any, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
 		return nil, SyntaxError
 	}
+	vars, rest, err := extractParams(p.This)
+	if err != nil {
+		return nil, err
+	}
+	body := p.Next
+	fn := &Lambda{vars: vars, rest: rest, body: body, env: env, defines: definedNames(body, env)}
+	if b, ok := body.(types.Pair); ok && b.Next == nil {
+		sc := &scope{names: fn.names()}
+		if isGoalForm(b.This, sc, env) {
+			// the errors are reported when the function is called
+			fn.goal, _ = compileGoal(b.This, sc, env)
+		}
+	}
+	return fn, nil
+}
+
+// The names of all the parameters, including the rest
+func (fn *Lambda) names() []types.Symbol {
+	if fn.rest == "" {
+		return fn.vars
+	}
+	return append(limited(fn.vars), fn.rest)
+}
+
+// The values of the parameters for the arguments, the remaining arguments
+// are collected into the list
+func (fn *Lambda) params(vals []any) ([]any, error) {
+	n := len(fn.vars)
+	if len(vals) < n || (fn.rest == "" && len(vals) > n) {
+		return nil, ArityError
+	}
+	if fn.rest == "" {
+		return vals, nil
+	}
+	return append(limited(vals[:n]), types.List(vals[n:]...)), nil
+}
+
+// Call the function, the body is returned for the tail call optimization
+func (fn *Lambda) call(args any, env *envir.Env) (any, *envir.Env, error) {
+	// arguments are evaluated in the env enclosing the lambda call
+	vals, err := evalArgs(args, env)
+	if err != nil {
+		return nil, env, err
+	}
+	return fn.tailCall(vals)
+}
+
+// Call the function with the already evaluated arguments,
+// the body is returned for the tail call optimization
+func (fn *Lambda) tailCall(vals []any) (any, *envir.Env, error) {
+	if fn.goal != nil {
+		goal, err := fn.makeGoal(vals)
+		return goal, fn.env, err
+	}
+	local, err := fn.bind(vals)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the body of the function is evaluated in the local env of the lambda
+	return partialEval(fn.body, local)
+}
+
+// The local env of the function, that inherits from the env where the lambda was defined
+func (fn *Lambda) bind(vals []any) (*envir.Env, error) {
+	vals, err := fn.params(vals)
+	if err != nil {
+		return nil, err
+	}
+	local := envir.NewEnvFrom(fn.env)
+	for i, name := range fn.names() {
+		local.Set(name, vals[i])
+	}
+	for _, name := range fn.defines {
+		local.Set(name, unassigned{})
+	}
+	return local, nil
+}
+
+// Call the compiled relation, return the goal
+func (fn *Lambda) callGoal(args any, env *envir.Env) (any, error) {
+	vals, err := evalArgs(args, env)
+	if err != nil {
+		return nil, err
+	}
+	return fn.makeGoal(vals)
+}
+
+// The goal for the arguments, the calls to the relations
+// defined with `defrel` are delayed
+func (fn *Lambda) makeGoal(vals []any) (Goal, error) {
+	vals = freezeAll(vals)
+	if fn.rel != "" {
+		if _, err := fn.params(vals); err != nil {
+			return nil, err
+		}
+		return Call{fn.rel, fn, vals}, nil
+	}
+	return fn.instantiate(vals)
+}
+
+// Create the goal from the compiled body of the relation for the arguments
+func (fn *Lambda) instantiate(vals []any) (Goal, error) {
+	vals, err := fn.params(vals)
+	if err != nil {
+		return nil, err
+	}
+	return fn.goal.instantiate(&frame{names: fn.names(), vals: vals, env: fn.env})
+}
+
+// Call the function with the already evaluated arguments
+func (fn *Lambda) apply(vals []any) (any, error) {
+	sexpr, env, err := fn.tailCall(vals)
+	if err != nil {
+		return nil, err
+	}
+	return Eval(sexpr, env)
+}
+
+func (fn *Lambda) String() string {
+	var vars []string
+	for _, v := range fn.vars {
+		vars = append(vars, string(v))
+	}
+	params := fmt.Sprintf("(%s)", strings.Join(vars, " "))
+	switch {
+	case fn.rest != "" && len(vars) == 0:
+		params = string(fn.rest)
+	case fn.rest != "":
+		params = fmt.Sprintf("(%s . %s)", strings.Join(vars, " "), fn.rest)
+	}
+	var body string
+	if p, ok := fn.body.(types.Pair); ok {
+		body = p.ToString()
+	}
+	return fmt.Sprintf("(lambda %s %s)", params, body)
+}
+
+// Evaluate the arguments of the call
+func evalArgs(args any, env *envir.Env) ([]any, error) {
 	var (
-		body = p.Next
-		vars []types.Symbol
-		err  error
+		vals []any
+		head = args
 	)
-	switch a := p.This.(type) {
-	case types.Pair:
-		vars, err = extractSymbols(a)
+	for head != nil {
+		p, ok := head.(types.Pair)
+		if !ok {
+			return nil, SyntaxError
+		}
+		val, err := Eval(p.This, env)
 		if err != nil {
 			return nil, err
 		}
-	case nil:
-		vars = nil
-	default:
-		return nil, NonList{p.This}
+		vals = append(vals, val)
+		head = p.Next
 	}
-	return func(args any, runEnv *envir.Env) (any, *envir.Env, error) {
-		local, err := createClosure(args, vars, env, runEnv)
-		if err != nil {
-			return nil, local, err
-		}
-		// the body of the function is evaluated in the local env of the lambda
-		return partialEval(body, local)
-	}, nil
+	return vals, nil
 }
 
 // Transform pair to slice
@@ -60,30 +213,28 @@ func extractSymbols(args types.Pair) ([]types.Symbol, error) {
 	return vars, nil
 }
 
-func createClosure(args any, vars []types.Symbol, parentEnv, env *envir.Env) (*envir.Env, error) {
-	// local env inherits from the env where the lambda was defined
-	local := envir.NewEnvFrom(parentEnv)
-	switch p := args.(type) {
-	case types.Pair:
-		i := 0
-		err := p.ForEach(func(val any) error {
-			if i >= len(vars) {
-				return ArityError
+// The names of the parameters `(a b ...)`, `(a b ... . rest)`, or `args`,
+// the last name is returned as rest
+func extractParams(params any) ([]types.Symbol, types.Symbol, error) {
+	var (
+		vars []types.Symbol
+		head = params
+	)
+	for {
+		switch p := head.(type) {
+		case nil:
+			return vars, "", nil
+		case types.Symbol:
+			return vars, p, nil
+		case types.Pair:
+			name, ok := p.This.(types.Symbol)
+			if !ok {
+				return nil, "", InvalidName{p.This}
 			}
-			// arguments are evaluated in the env enclosing the lambda call
-			val, err := Eval(val, env)
-			local.Set(vars[i], val)
-			i++
-			return err
-		})
-		if err != nil {
-			return nil, err
-		}
-		if i != len(vars) {
-			return nil, ArityError
+			vars = append(vars, name)
+			head = p.Next
+		default:
+			return nil, "", NonList{params}
 		}
-		return local, err
-	default:
-		return local, nil
 	}
 }
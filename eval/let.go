@@ -8,35 +8,192 @@ import (
 // `let` procedure
 //
 //	(let ((key1 value1) (key2 value2) ...) expr1 expr2 ...)
+//	(let name ((key1 value1) (key2 value2) ...) expr1 expr2 ...)
 func let(args any, env *envir.Env) (any, *envir.Env, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
 		return nil, nil, SyntaxError
 	}
+	if name, ok := p.This.(types.Symbol); ok {
+		return namedLet(name, p.Next, env)
+	}
 	local := envir.NewEnvFrom(env)
-	switch b := p.This.(type) {
-	case types.Pair:
-		if err := setBindings(b, local, env); err != nil {
-			return nil, nil, err
+	if err := setBindings(p.This, local, env); err != nil {
+		return nil, nil, err
+	}
+	declareDefines(p.Next, local)
+	return partialEval(p.Next, local)
+}
+
+// `let*` procedure, the values are evaluated in order,
+// and they can refer to the previous bindings
+//
+//	(let* ((key1 value1) (key2 value2) ...) expr1 expr2 ...)
+func letStar(args any, env *envir.Env) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	// every binding has its own env, so the closures
+	// refer to the bindings preceding them
+	local := env
+	err := forEachElem(p.This, func(val any) error {
+		b, ok := val.(types.Pair)
+		if !ok {
+			return NonList{val}
 		}
-	case nil:
-	default:
-		return nil, nil, NonList{p.This}
+		parent := local
+		local = envir.NewEnvFrom(parent)
+		return bind(b, local, parent)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	local = envir.NewEnvFrom(local)
+	declareDefines(p.Next, local)
+	return partialEval(p.Next, local)
+}
+
+// `letrec` and `letrec*` procedures, the values are evaluated in order in the env
+// that holds all the bindings, so the functions can be mutually recursive
+//
+//	(letrec ((key1 value1) (key2 value2) ...) expr1 expr2 ...)
+func letrec(args any, env *envir.Env) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
 	}
+	local := envir.NewEnvFrom(env)
+	if err := declare(p.This, local); err != nil {
+		return nil, nil, err
+	}
+	if err := setBindings(p.This, local, local); err != nil {
+		return nil, nil, err
+	}
+	declareDefines(p.Next, local)
 	return partialEval(p.Next, local)
 }
 
+// Named `let` calls the body as a function with the given name, that
+// can be called recursively, e.g. for the loops
+func namedLet(name types.Symbol, args any, env *envir.Env) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	var (
+		vars []types.Symbol
+		vals []any
+	)
+	err := forEachElem(p.This, func(val any) error {
+		b, ok := val.(types.Pair)
+		if !ok {
+			return NonList{val}
+		}
+		key, sexpr, err := extractBinding(b)
+		if err != nil {
+			return err
+		}
+		// arguments are evaluated in env enclosing let
+		val, err = Eval(sexpr, env)
+		if err != nil {
+			return err
+		}
+		vars = append(vars, key)
+		vals = append(vals, val)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	local := envir.NewEnvFrom(env)
+	fn, err := newLambda(types.Pair{This: symbolsList(vars), Next: p.Next}, local)
+	if err != nil {
+		return nil, nil, err
+	}
+	local.Set(name, fn)
+	return fn.(*Lambda).tailCall(vals)
+}
+
 // Iterate through the bindings ((key1 value1) (key2 value2) ...) and set them to an environment
-func setBindings(bindings types.Pair, local, parent *envir.Env) error {
-	return bindings.ForEach(func(val any) error {
+func setBindings(bindings any, local, parent *envir.Env) error {
+	return forEachElem(bindings, func(val any) error {
 		p, ok := val.(types.Pair)
 		if !ok {
-			return &NonList{val}
+			return NonList{val}
 		}
 		return bind(p, local, parent)
 	})
 }
 
+// Declare the names from the bindings in the env, so they can be referred to
+// before their values are set
+func declare(bindings any, env *envir.Env) error {
+	return forEachElem(bindings, func(val any) error {
+		p, ok := val.(types.Pair)
+		if !ok {
+			return NonList{val}
+		}
+		name, _, err := extractBinding(p)
+		if err != nil {
+			return err
+		}
+		env.Set(name, unassigned{})
+		return nil
+	})
+}
+
+// The value of the name that was declared, but its value was not set yet,
+// reading it is an error
+type unassigned struct{}
+
+// Declare the names defined inside the body, so the definitions are local
+// to the body from its beginning, as in `letrec*`
+func declareDefines(body any, env *envir.Env) {
+	for _, name := range definedNames(body, env) {
+		env.Set(name, unassigned{})
+	}
+}
+
+// The names defined with `define` at the top of the body
+func definedNames(body any, env *envir.Env) []types.Symbol {
+	var acc []types.Symbol
+	for p, ok := body.(types.Pair); ok; p, ok = p.Next.(types.Pair) {
+		form, ok := p.This.(types.Pair)
+		if !ok {
+			continue
+		}
+		if name, ok := form.This.(types.Symbol); !ok || !isDefine(name, env) {
+			continue
+		}
+		target, ok := form.Next.(types.Pair)
+		if !ok {
+			continue
+		}
+		// (define (name args ...) body ...)
+		if head, ok := target.This.(types.Pair); ok {
+			target = head
+		}
+		if name, ok := target.This.(types.Symbol); ok {
+			acc = append(acc, name)
+		}
+	}
+	return acc
+}
+
+func isDefine(name types.Symbol, env *envir.Env) bool {
+	fn, _ := lookup(name, env)
+	return isBuiltin(fn, define)
+}
+
+func symbolsList(names []types.Symbol) any {
+	var acc []any
+	for _, name := range names {
+		acc = append(acc, name)
+	}
+	return types.List(acc...)
+}
+
 // Bind value to the name in the local env
 func bind(binding types.Pair, local, parent *envir.Env) error {
 	name, sexpr, err := extractBinding(binding)
@@ -0,0 +1,137 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/types"
+)
+
+// The time limit for a single run, or the evaluation of a top-level expression,
+// no limit when zero, it is used for the environments not owned by an Interpreter
+var Timeout time.Duration
+
+// The maximal number of the goals queried and the expressions evaluated in a single run,
+// or the evaluation of a top-level expression, no limit when zero, it is used
+// for the environments not owned by an Interpreter
+var MaxSteps int
+
+// The maximal depth of the nested evaluations, the deeper recursion fails with
+// ErrMaxDepth, instead of exhausting the Go stack, no limit when zero, it is used
+// for the environments not owned by an Interpreter
+var MaxDepth int
+
+var (
+	ErrMaxSteps = errors.New("step limit exceeded")
+	ErrTimeout  = errors.New("time limit exceeded")
+	ErrMaxDepth = errors.New("recursion depth exceeded")
+)
+
+// The search was stopped before it finished, because it exceeded the limits
+// or it was cancelled, the answers found before are kept
+type LimitError struct {
+	Err     error
+	Answers []any
+}
+
+func (e LimitError) Error() string {
+	return fmt.Sprintf("%v, answers found so far: %s", e.Err, types.ToString(types.List(e.Answers...)))
+}
+
+func (e LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Check if the search was stopped by the limits or the context
+func isLimit(err error) bool {
+	return errors.Is(err, ErrMaxSteps) || errors.Is(err, ErrTimeout) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Checking the time and the context is slower than counting the steps,
+// so it is done only every n-th step
+const checkEvery = 1024
+
+// The budget of a single run, shared by all its streams
+type budget struct {
+	ctx      context.Context
+	deadline time.Time
+	maxSteps int
+	steps    int
+}
+
+func newBudget(ctx context.Context, timeout time.Duration, maxSteps int) *budget {
+	b := &budget{ctx: ctx, maxSteps: maxSteps}
+	if timeout > 0 {
+		b.deadline = time.Now().Add(timeout)
+	}
+	return b
+}
+
+// Count the step, return the error when the budget run out
+func (b *budget) step() error {
+	if b == nil {
+		return nil
+	}
+	b.steps++
+	if b.maxSteps > 0 && b.steps > b.maxSteps {
+		return ErrMaxSteps
+	}
+	if b.steps%checkEvery == 0 {
+		if err := b.ctx.Err(); err != nil {
+			return err
+		}
+		if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+			return ErrTimeout
+		}
+	}
+	return nil
+}
+
+// The state of the evaluation, shared by all the environments derived from the same
+// global environment
+type evalState struct {
+	// the budget that the evaluated expressions are charged against, nil when
+	// nothing is evaluated
+	budget *budget
+	// the depth of the nested evaluations, and its limit
+	depth, maxDepth int
+}
+
+// Charge the evaluations against the budget, return the function restoring the previous one
+func (st *evalState) use(b *budget) func() {
+	prev := st.budget
+	st.budget = b
+	return func() { st.budget = prev }
+}
+
+// Run the goals, but stop after the number of steps
+//
+//	(run-with-limit steps n (x ...) g1 g2 ...)
+func runWithLimit(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok || p.Next == nil {
+		return nil, SyntaxError
+	}
+	val, err := Eval(p.This, env)
+	if err != nil {
+		return nil, err
+	}
+	// zero steps would mean no limit for the budget
+	steps, err := count(val)
+	if err != nil {
+		return nil, err
+	}
+	if steps == 0 {
+		return nil, WrongArg{val}
+	}
+	n, rest, err := runCount(p.Next)
+	if err != nil {
+		return nil, err
+	}
+	timeout, _ := limits(env)
+	return runGoals(n, rest, env, occursCheck(env), newBudget(context.Background(), timeout, steps))
+}
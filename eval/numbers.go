@@ -0,0 +1,184 @@
+package eval
+
+import (
+	"math/big"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+// Compare the numbers, the comparisons with NaN are unordered
+func compare(a, b any) (int, bool, error) {
+	if !types.IsNumber(a) {
+		return 0, false, NaN{a}
+	}
+	if !types.IsNumber(b) {
+		return 0, false, NaN{b}
+	}
+	c, ok := types.Compare(a, b)
+	return c, ok, nil
+}
+
+// The numbers are equal if they have the same value, regardless of their exactness,
+// the lists and the vectors are equal if all their elements are equal,
+// the bytevectors if they have the same bytes
+func equal(a, b any) bool {
+	return equalIn(a, b, nil)
+}
+
+// The pairs of vectors that were already reached are assumed to be equal,
+// so the comparison of the cyclic vectors terminates
+func equalIn(a, b any, seen map[[2]*types.Vector]bool) bool {
+	if types.IsNumber(a) && types.IsNumber(b) {
+		c, ok := types.Compare(a, b)
+		return ok && c == 0
+	}
+	if p, ok := a.(types.Pair); ok {
+		if q, ok := b.(types.Pair); ok {
+			return equalIn(p.This, q.This, seen) && equalIn(p.Next, q.Next, seen)
+		}
+	}
+	if u, ok := a.(*types.Vector); ok {
+		if v, ok := b.(*types.Vector); ok && len(u.Elems) == len(v.Elems) {
+			key := [2]*types.Vector{u, v}
+			if seen[key] {
+				return true
+			}
+			if seen == nil {
+				seen = make(map[[2]*types.Vector]bool)
+			}
+			seen[key] = true
+			for i := range u.Elems {
+				if !equalIn(u.Elems[i], v.Elems[i], seen) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return types.Eqv(a, b)
+}
+
+// The exact zero cannot be the divisor, unlike the inexact zero,
+// that gives the infinity or NaN
+func isExactZero(v any) bool {
+	return types.IsExact(v) && equal(v, 0)
+}
+
+// The single number argument
+func number(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	if !types.IsNumber(args[0]) {
+		return nil, NaN{args[0]}
+	}
+	return args[0], nil
+}
+
+// (exact->inexact z)
+func exactToInexact(args []any) (any, error) {
+	z, err := number(args)
+	if err != nil {
+		return nil, err
+	}
+	return types.ToInexact(z), nil
+}
+
+// (floor x)
+func floor(args []any) (any, error) {
+	x, err := number(args)
+	if err != nil {
+		return nil, err
+	}
+	return types.Floor(x), nil
+}
+
+// The square root, it is exact for the squares of the exact numbers
+//
+//	(sqrt z)
+func sqrt(args []any) (any, error) {
+	z, err := number(args)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := types.Sqrt(z)
+	if !ok {
+		return nil, WrongArg{z}
+	}
+	return root, nil
+}
+
+// Raise the base to the power, it is exact for the exact base and integer exponent
+//
+//	(expt base exp)
+func expt(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, ArityError
+	}
+	for _, v := range args {
+		if !types.IsNumber(v) {
+			return nil, NaN{v}
+		}
+	}
+	if isExactZero(args[0]) && types.IsExact(args[1]) {
+		if c, _ := types.Compare(args[1], 0); c < 0 {
+			return nil, ArithmeticError{"expt", args, DivisionByZero}
+		}
+	}
+	if e, ok := types.ToBigInt(args[1]); ok && types.IsExact(args[0]) {
+		// the lower bound of the size of the exact result
+		size := big.NewInt(int64(max(types.BitLen(args[0])-1, 0)))
+		if size.Mul(size, e.Abs(e)).Cmp(big.NewInt(maxBits)) > 0 {
+			return nil, ArithmeticError{"expt", args, NumberTooLarge}
+		}
+	}
+	return types.Expt(args[0], args[1]), nil
+}
+
+// The exact results larger than this number of bits are not
+// computed, as they could exhaust the memory
+const maxBits = 1 << 22
+
+// Multiply the numbers, unless the exact result would be too large
+func mul(a, b any) (any, error) {
+	if types.BitLen(a)+types.BitLen(b) > maxBits {
+		return nil, ArithmeticError{"*", []any{a, b}, NumberTooLarge}
+	}
+	return types.Mul(a, b), nil
+}
+
+// Divide the numbers, unless the divisor is the exact zero,
+// or the exact result would be too large
+func div(a, b any) (any, error) {
+	if isExactZero(b) {
+		return nil, ArithmeticError{"/", []any{a, b}, DivisionByZero}
+	}
+	if types.BitLen(a)+types.BitLen(b) > maxBits {
+		return nil, ArithmeticError{"/", []any{a, b}, NumberTooLarge}
+	}
+	return types.Div(a, b), nil
+}
+
+// Convert the number to the string, the radix is 2, 8, 10, or 16, it is ignored
+// for the inexact numbers
+//
+//	(number->string z)
+//	(number->string z radix)
+func numberToString(args []any) (any, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, ArityError
+	}
+	z := args[0]
+	if !types.IsNumber(z) {
+		return nil, NaN{z}
+	}
+	radix := 10
+	if len(args) == 2 {
+		r, ok := args[1].(int)
+		if !ok || (r != 2 && r != 8 && r != 10 && r != 16) {
+			return nil, WrongArg{args[1]}
+		}
+		radix = r
+	}
+	return types.FormatNumber(z, radix), nil
+}
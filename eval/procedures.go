@@ -1,104 +1,207 @@
 package eval
 
 import (
+	"unicode"
+
 	"github.com/twolodzko/kanren/envir"
 	"github.com/twolodzko/kanren/types"
 )
 
 func DefaultEnv() *envir.Env {
 	env := envir.NewEnv()
+	env.Owner = &evalState{}
 	// scheme
 	env.Set("quote", quote)
 	env.Set("unquote", unquote)
 	env.Set("quasiquote", quasiQuote)
 	env.Set("lambda", newLambda)
 	env.Set("let", let)
+	env.Set("let*", letStar)
+	env.Set("letrec", letrec)
+	env.Set("letrec*", letrec)
 	env.Set("define", define)
-	env.Set("car", car)
-	env.Set("cdr", cdr)
-	env.Set("cons", cons)
+	env.Set("set!", set)
+	env.Set("begin", begin)
+	env.Set("when", when)
+	env.Set("unless", unless)
+	env.Set("case", caseForm)
+	env.Set("do", do)
+	env.Set("define-syntax", defineSyntax)
+	env.Set("let-syntax", letSyntax)
+	env.Set("letrec-syntax", letrecSyntax)
+	env.Set("syntax-rules", syntaxRules)
+	env.Set("car", Procedure(car))
+	env.Set("cdr", Procedure(cdr))
+	env.Set("cons", Procedure(cons))
 	env.Set("else", true)
 	env.Set("load", load)
-	env.Set("null?", isNull)
-	env.Set("pair?", isPair)
+	env.Set("null?", Procedure(isNull))
+	env.Set("pair?", Procedure(isPair))
 	env.Set("and", and)
 	env.Set("or", or)
-	env.Set("not", not)
+	env.Set("not", Procedure(not))
 	env.Set("cond", cond)
-	env.Set("list", list)
-	env.Set("=", func(args any, env *envir.Env) (any, error) {
-		return cmp(args, env, func(a, b any) (bool, error) {
-			return a == b, nil
+	env.Set("list", Procedure(list))
+	env.Set("=", Procedure(func(args []any) (any, error) {
+		return cmp(args, func(a, b any) (bool, error) {
+			return equal(a, b), nil
 		})
-	})
-	env.Set(">", func(args any, env *envir.Env) (any, error) {
-		return cmp(args, env, func(a, b any) (bool, error) {
-			ai, ok := a.(int)
-			if !ok {
-				return false, NaN{a}
-			}
-			bi, ok := b.(int)
-			if !ok {
-				return false, NaN{b}
-			}
-			return ai > bi, nil
+	}))
+	env.Set(">", Procedure(func(args []any) (any, error) {
+		return cmp(args, func(a, b any) (bool, error) {
+			c, ok, err := compare(a, b)
+			return ok && c > 0, err
 		})
-	})
-	env.Set("<", func(args any, env *envir.Env) (any, error) {
-		return cmp(args, env, func(a, b any) (bool, error) {
-			ai, ok := a.(int)
-			if !ok {
-				return false, NaN{a}
-			}
-			bi, ok := b.(int)
-			if !ok {
-				return false, NaN{b}
+	}))
+	env.Set("<", Procedure(func(args []any) (any, error) {
+		return cmp(args, func(a, b any) (bool, error) {
+			c, ok, err := compare(a, b)
+			return ok && c < 0, err
+		})
+	}))
+	env.Set("+", op(0, func(a, b any) (any, error) {
+		return types.Add(a, b), nil
+	}))
+	env.Set("-", Procedure(func(args []any) (any, error) {
+		if len(args) == 1 {
+			if !types.IsNumber(args[0]) {
+				return nil, NaN{args[0]}
 			}
-			return ai < bi, nil
+			return types.Sub(0, args[0]), nil
+		}
+		return foldLeft(args, func(a, b any) (any, error) {
+			return types.Sub(a, b), nil
 		})
-	})
-	env.Set("+", op(func(a, b int) int { return a + b }))
-	env.Set("-", func(args any, env *envir.Env) (any, error) {
-		p, ok := args.(types.Pair)
-		if !ok {
-			return nil, SyntaxError
+	}))
+	env.Set("*", op(1, mul))
+	env.Set("/", Procedure(func(args []any) (any, error) {
+		if len(args) == 1 {
+			if !types.IsNumber(args[0]) {
+				return nil, NaN{args[0]}
+			}
+			return div(1, args[0])
 		}
-		if p.Next == nil {
-			val, err := Eval(p.This, env)
-			if err != nil {
-				return nil, err
+		return foldLeft(args, div)
+	}))
+	env.Set("%", Procedure(func(args []any) (any, error) {
+		return foldLeft(args, func(a, b any) (any, error) {
+			if isExactZero(b) {
+				return nil, ArithmeticError{"%", []any{a, b}, DivisionByZero}
 			}
-			num, ok := val.(int)
+			r, ok := types.Remainder(a, b)
 			if !ok {
-				return nil, NaN{val}
+				return nil, ArithmeticError{"%", []any{a, b}, TypeError}
 			}
-			return -num, nil
-		}
-		return foldLeft(p, env, func(a, b int) (int, error) {
-			return a - b, nil
+			return r, nil
 		})
-	})
-	env.Set("*", op(func(a, b int) int { return a * b }))
-	env.Set("/", op(func(a, b int) int { return a / b }))
-	env.Set("%", op(func(a, b int) int { return a % b }))
+	}))
+	env.Set("exact->inexact", Procedure(exactToInexact))
+	env.Set("floor", Procedure(floor))
+	env.Set("sqrt", Procedure(sqrt))
+	env.Set("expt", Procedure(expt))
+	env.Set("number->string", Procedure(numberToString))
+	env.Set("apply", Procedure(apply))
+	env.Set("string?", Procedure(isType[string]))
+	env.Set("string-length", Procedure(stringLength))
+	env.Set("string-ref", Procedure(stringRef))
+	env.Set("substring", Procedure(substring))
+	env.Set("string-append", Procedure(stringAppend))
+	env.Set("string->list", Procedure(stringToList))
+	env.Set("list->string", Procedure(listToString))
+	env.Set("string->symbol", Procedure(stringToSymbol))
+	env.Set("symbol->string", Procedure(symbolToString))
+	env.Set("string=?", compareStrings(func(a, b string) bool { return a == b }))
+	env.Set("string<?", compareStrings(func(a, b string) bool { return a < b }))
+	env.Set("string>?", compareStrings(func(a, b string) bool { return a > b }))
+	env.Set("symbol?", Procedure(isType[types.Symbol]))
+	env.Set("char?", Procedure(isType[types.Char]))
+	env.Set("char=?", compareChars(func(a, b types.Char) bool { return a == b }))
+	env.Set("char<?", compareChars(func(a, b types.Char) bool { return a < b }))
+	env.Set("char>?", compareChars(func(a, b types.Char) bool { return a > b }))
+	env.Set("char-alphabetic?", charPredicate(unicode.IsLetter))
+	env.Set("char-numeric?", charPredicate(unicode.IsDigit))
+	env.Set("char-whitespace?", charPredicate(unicode.IsSpace))
+	env.Set("char-upper-case?", charPredicate(unicode.IsUpper))
+	env.Set("char-lower-case?", charPredicate(unicode.IsLower))
+	env.Set("char-upcase", charMap(unicode.ToUpper))
+	env.Set("char-downcase", charMap(unicode.ToLower))
+	env.Set("char->integer", Procedure(charToInteger))
+	env.Set("integer->char", Procedure(integerToChar))
+	env.Set("vector?", Procedure(isType[*types.Vector]))
+	env.Set("vector", Procedure(vector))
+	env.Set("make-vector", Procedure(makeVector))
+	env.Set("vector-length", Procedure(vectorLength))
+	env.Set("vector-ref", Procedure(vectorRef))
+	env.Set("vector-set!", Procedure(vectorSet))
+	env.Set("vector->list", Procedure(vectorToList))
+	env.Set("list->vector", Procedure(listToVector))
+	env.Set("bytevector?", Procedure(isType[*types.Bytevector]))
+	env.Set("bytevector", Procedure(bytevector))
+	env.Set("make-bytevector", Procedure(makeBytevector))
+	env.Set("bytevector-length", Procedure(bytevectorLength))
+	env.Set("bytevector-u8-ref", Procedure(bytevectorRef))
+	env.Set("bytevector-u8-set!", Procedure(bytevectorSet))
+	env.Set("bytevector-append", Procedure(bytevectorAppend))
+	env.Set("utf8->string", Procedure(utf8ToString))
+	env.Set("string->utf8", Procedure(stringToUtf8))
 	// extras
 	env.Set("test-check", testCheck)
 	// kanren
 	env.Set("run", run)
 	env.Set("run*", runAll)
-	env.Set("succeed", ConstGoal{"succeed", true})
-	env.Set("fail", ConstGoal{"fail", false})
-	env.Set("==", newUnify)
-	env.Set("fresh", newFresh)
-	env.Set("conde", newConde)
-	env.Set("project", newProject)
+	env.Set("run/no-occurs", runNoOccurs)
+	env.Set("run*/no-occurs", runAllNoOccurs)
+	env.Set("run-with-limit", runWithLimit)
+	env.Set("succeed", Succeed)
+	env.Set("fail", Fail)
+	env.Set("==", newPrimitive("==", 2, newUnify))
+	env.Set("=/=", newPrimitive("=/=", 2, newDisequality))
+	env.Set("symbolo", newTypeConstraint("symbolo", "sym"))
+	env.Set("numbero", newTypeConstraint("numbero", "num"))
+	env.Set("stringo", newTypeConstraint("stringo", "str"))
+	env.Set("absento", newPrimitive("absento", 2, newAbsento))
+	env.Set("infd", newPrimitive("infd", -2, newInFD))
+	env.Set("domfd", newPrimitive("domfd", 2, newInFD))
+	env.Set("=fd", newFDGoal("=fd", 2))
+	env.Set("=/=fd", newFDGoal("=/=fd", 2))
+	env.Set("<fd", newFDGoal("<fd", 2))
+	env.Set("<=fd", newFDGoal("<=fd", 2))
+	env.Set("+fd", newFDGoal("+fd", 3))
+	env.Set("*fd", newFDGoal("*fd", 3))
+	env.Set("distinctfd", newFDGoal("distinctfd", 1))
+	env.Set("range", Procedure(intRange))
+	env.Set("fresh", goalForm{"fresh", compileFresh})
+	env.Set("conde", newCond("conde", func(b branches) Goal { return Conde{b} }))
+	env.Set("conda", newCond("conda", func(b branches) Goal { return Conda{b} }))
+	env.Set("condu", newCond("condu", func(b branches) Goal { return Condu{b} }))
+	env.Set("onceo", goalForm{"onceo", compileOnceo})
+	env.Set("project", goalForm{"project", compileProject})
+	env.Set("matche", goalForm{"matche", compileMatche})
+	env.Set("lambdae", newLambdae)
+	env.Set("λe", newLambdae)
+	env.Set("defrel", defrel)
+	env.Set("tabled", newTabled)
+	env.Set("defrel-tabled", defrelTabled)
+	nameBuiltins(env)
 	return envir.NewEnvFrom(env)
 }
 
-func op(fn func(a, b int) int) func(any, *envir.Env) (any, error) {
-	return func(args any, env *envir.Env) (any, error) {
-		return foldLeft(args, env, func(a, b int) (int, error) {
-			return fn(a, b), nil
-		})
+// Keep the names of the builtins, so they can be printed
+func nameBuiltins(env *envir.Env) {
+	for name, val := range env.Vars {
+		switch val.(type) {
+		case Procedure, Syntax, tco:
+			env.Vars[name] = &builtin{string(name), val}
+		}
+	}
+}
+
+// Fold the numbers with the operation, the identity is the result for no numbers
+func op(identity any, fn func(a, b any) (any, error)) Procedure {
+	return func(args []any) (any, error) {
+		if len(args) == 0 {
+			return identity, nil
+		}
+		return foldLeft(args, fn)
 	}
 }
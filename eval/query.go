@@ -0,0 +1,171 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"sync"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/parser"
+	"github.com/twolodzko/kanren/types"
+)
+
+// Query produces the answers of the goals one at a time, the search
+// is resumed only when the next answer is requested
+type Query struct {
+	target any
+	rest   Suspension
+	debug  io.Writer
+	// the evaluations made while searching are charged against the budget
+	// of the query, when the state is not nil
+	state  *evalState
+	budget *budget
+	// held while searching for the answers, when not nil
+	lock *sync.Mutex
+}
+
+// NewQuery compiles the `((x ...) g1 g2 ...)` query, as in the arguments of `run*`
+func NewQuery(args any, env *envir.Env) (*Query, error) {
+	return NewQueryContext(context.Background(), args, env)
+}
+
+// NewQueryContext compiles the query that stops searching when the context is cancelled,
+// the Timeout and MaxSteps limits are also used
+func NewQueryContext(ctx context.Context, args any, env *envir.Env) (*Query, error) {
+	timeout, maxSteps := limits(env)
+	return newQuery(args, env, occursCheck(env), newBudget(ctx, timeout, maxSteps))
+}
+
+// QueryString parses and compiles the query, e.g. `(q) (== q 1)`
+func QueryString(code string, env *envir.Env) (*Query, error) {
+	sexprs, err := parser.NewParser(code).Read()
+	if err != nil {
+		return nil, err
+	}
+	return NewQuery(types.List(sexprs...), env)
+}
+
+func newQuery(args any, env *envir.Env, occursCheck bool, b *budget) (*Query, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	binding, ok := p.This.(types.Pair)
+	if !ok || p.Next == nil {
+		return nil, WrongArg{p.This}
+	}
+	names, err := extractSymbols(binding)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		vars []types.Variable
+		vals []any
+	)
+	for _, name := range names {
+		v := types.NewVariable(string(name))
+		vars = append(vars, v)
+		vals = append(vals, v)
+	}
+	body, ok := p.Next.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	// the goals are compiled once, so backtracking does not evaluate them again
+	code, err := compileGoals(body, &scope{names: names}, env)
+	if err != nil {
+		return nil, err
+	}
+	goals, err := instantiateAll(code, &frame{names: names, vals: vals, env: env})
+	if err != nil {
+		return nil, err
+	}
+	q := goalQuery(vars, goals, occursCheck, b, debugOut(env))
+	q.state, q.budget = stateOf(env), b
+	return q, nil
+}
+
+// NewGoalQuery creates the query for the goals built in Go, the answers are the values
+// of the variables, as in `run*`, the package settings are used
+func NewGoalQuery(vars []types.Variable, goals ...Goal) *Query {
+	var out io.Writer
+	if Debug {
+		out = os.Stdout
+	}
+	return goalQuery(vars, goals, OccursCheck, newBudget(context.Background(), Timeout, MaxSteps), out)
+}
+
+// Query for the values of the variables, labelled if they have finite domains
+func goalQuery(vars []types.Variable, goals []Goal, occursCheck bool, b *budget, debug io.Writer) *Query {
+	// for multiple variables the answer is the list of their values
+	var target any = vars[0]
+	if len(vars) > 1 {
+		var acc []any
+		for _, v := range vars {
+			acc = append(acc, v)
+		}
+		target = types.List(acc...)
+	}
+	goals = append(limited(goals), Labelling{target})
+	return &Query{target: target, debug: debug, rest: func() (Answers, error) {
+		s := NewStream()
+		s.occursCheck = occursCheck
+		s.tables = make(tables)
+		s.budget = b
+		s.debug = debug
+		for _, v := range vars {
+			s.birthRecord(v)
+		}
+		return queryAll(goals, s)
+	}}
+}
+
+// Next searches for the next answer, ok is false when there are no more answers
+func (q *Query) Next() (answer any, ok bool, err error) {
+	if q.lock != nil {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+	}
+	if q.state != nil {
+		defer q.state.use(q.budget)()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			q.rest = nil
+			answer, ok, err = nil, false, RuntimeError{r}
+		}
+	}()
+	s, rest, err := pull(q.rest)
+	if err != nil {
+		q.rest = nil
+		return nil, false, err
+	}
+	q.rest = rest
+	if s == nil {
+		return nil, false, nil
+	}
+	r := s.reify(q.target)
+	if q.debug != nil {
+		fmt.Fprintf(q.debug, "  result: %v\n", types.ToString(r))
+	}
+	return r, true, nil
+}
+
+// All iterates over the remaining answers, the search stops when the loop ends
+func (q *Query) All() iter.Seq2[any, error] {
+	return func(yield func(any, error) bool) {
+		for {
+			r, ok, err := q.Next()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !ok || !yield(r, nil) {
+				return
+			}
+		}
+	}
+}
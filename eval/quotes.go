@@ -41,6 +41,17 @@ func quasiQuote(args any, env *envir.Env) (any, error) {
 }
 
 func unquoteRecursively(val any, numQuotes int, env *envir.Env) (any, error) {
+	if v, ok := val.(*types.Vector); ok {
+		elems := make([]any, len(v.Elems))
+		for i, e := range v.Elems {
+			u, err := unquoteRecursively(e, numQuotes, env)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = u
+		}
+		return types.NewVector(elems...), nil
+	}
 	p, ok := val.(types.Pair)
 	if !ok {
 		return val, nil
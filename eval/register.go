@@ -0,0 +1,158 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/types"
+)
+
+var errorType = reflect.TypeFor[error]()
+
+// Register the Go function as a Scheme procedure. The arguments are evaluated
+// and converted to the types of the parameters as in ToGo, and the returned
+// value as in FromGo. The function can return a value, an error, or both.
+//
+//	Register(env, "add", func(a, b int) int { return a + b })
+func Register(env *envir.Env, name string, fn any) error {
+	f := reflect.ValueOf(fn)
+	t := f.Type()
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("%v is not a function", fn)
+	}
+	switch {
+	case t.NumOut() > 2:
+		return fmt.Errorf("%s returns more than two values", name)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return fmt.Errorf("the second value returned by %s is not an error", name)
+	}
+	env.Set(types.Symbol(name), &builtin{name, Procedure(func(vals []any) (any, error) {
+		in, err := fromScheme(vals, t)
+		if err != nil {
+			return nil, err
+		}
+		return toScheme(f.Call(in))
+	})})
+	return nil
+}
+
+// Relation implemented in Go, it gets the arguments with the bound
+// variables replaced by their values and returns the stream of the answers:
+// nil when the goal failed, a single *Stream created with Unify, or the
+// answers created lazily with Yield, so the stream can be infinite
+type GoalFunc func(s *Stream, args []any) (Answers, error)
+
+// Register the relation implemented in Go, the goal is called with
+// the given number of arguments
+//
+//	RegisterGoal(env, "lengtho", 2, func(s *Stream, args []any) (Answers, error) { ... })
+func RegisterGoal(env *envir.Env, name string, arity int, fn GoalFunc) {
+	env.Set(types.Symbol(name), newPrimitive(name, arity, func(args []any) (Goal, error) {
+		return goFuncGoal{name, fn, args}, nil
+	}))
+}
+
+// Unify the values, return the extended stream, or nil if they cannot be unified,
+// the stream itself is not changed
+func (s *Stream) Unify(u, v any) *Stream {
+	s = s.fork()
+	if s.unifyVerify(u, v) {
+		return s
+	}
+	return nil
+}
+
+// The answer followed by the rest of the answers, that are computed only
+// when they are needed, the nil answer is skipped, as the failed Unify,
+// and the nil rest means no more answers
+//
+//	var from func(i int) Suspension
+//	from = func(i int) Suspension {
+//		return func() (Answers, error) {
+//			return Yield(s.Unify(x, i), from(i+1)), nil
+//		}
+//	}
+func Yield(answer *Stream, rest Suspension) Answers {
+	switch {
+	case rest == nil && answer == nil:
+		return nil
+	case rest == nil:
+		return answer
+	case answer == nil:
+		return rest
+	default:
+		return Choice{answer, rest}
+	}
+}
+
+// Replace the bound variables in the value by their values
+func (s *Stream) Walk(v any) any {
+	return s.deepWalk(v)
+}
+
+type goFuncGoal struct {
+	name string
+	fn   GoalFunc
+	args []any
+}
+
+func (g goFuncGoal) Query(s *Stream) (Answers, error) {
+	var args []any
+	for _, a := range g.args {
+		args = append(args, s.deepWalk(a))
+	}
+	a, err := g.fn(s, args)
+	if u, ok := a.(*Stream); ok && u == nil {
+		// the failed Unify
+		return nil, err
+	}
+	return a, err
+}
+
+func (g goFuncGoal) String() string {
+	var acc []string
+	for _, a := range g.args {
+		acc = append(acc, types.ToString(a))
+	}
+	return fmt.Sprintf("(%s %s)", g.name, strings.Join(acc, " "))
+}
+
+// Convert the values to the arguments of the function of type t
+func fromScheme(vals []any, t reflect.Type) ([]reflect.Value, error) {
+	n := t.NumIn()
+	if (!t.IsVariadic() && len(vals) != n) || (t.IsVariadic() && len(vals) < n-1) {
+		return nil, ArityError
+	}
+	var acc []reflect.Value
+	for i, val := range vals {
+		var param reflect.Type
+		if t.IsVariadic() && i >= n-1 {
+			param = t.In(n - 1).Elem()
+		} else {
+			param = t.In(i)
+		}
+		v, err := toGo(val, param)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, v)
+	}
+	return acc, nil
+}
+
+// Convert the values returned by the function to the Scheme value
+func toScheme(out []reflect.Value) (any, error) {
+	var err error
+	if n := len(out); n > 0 && out[n-1].Type() == errorType {
+		if !out[n-1].IsNil() {
+			err = out[n-1].Interface().(error)
+		}
+		out = out[:n-1]
+	}
+	if len(out) == 0 || err != nil {
+		return nil, err
+	}
+	return fromGo(out[0])
+}
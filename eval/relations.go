@@ -0,0 +1,265 @@
+package eval
+
+import (
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/types"
+)
+
+// Define the relation, the calls to it are delayed (inverse-eta delay), so the goals
+// of the body are created only when they are queried, and the recursive relations
+// can be built from any Scheme expressions
+//
+//	(defrel (name args ...) g1 g2 ...)
+func defrel(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	head, ok := p.This.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	name, ok := head.This.(types.Symbol)
+	if !ok {
+		return nil, InvalidName{head.This}
+	}
+	vars, rest, err := extractParams(head.Next)
+	if err != nil {
+		return nil, err
+	}
+	body, ok := p.Next.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	fn := &Lambda{vars: vars, rest: rest, body: body, env: env, rel: name}
+	// the body is the same as `(fresh () g1 g2 ...)`
+	goals, err := compileGoals(body, &scope{parent: &scope{names: fn.names()}}, env)
+	if err != nil {
+		return nil, err
+	}
+	fn.goal = freshCode{nil, body, goals, nil}
+	env.Set(name, fn)
+	return fn, nil
+}
+
+// `matche` matches the values with the patterns of the clauses, like `conde`
+// with a branch for each clause (see Byrd, 2009)
+//
+//	(matche (expr ...) ((pattern ...) g1 g2 ...) ...)
+//	(matche x (pattern g1 g2 ...) ...)
+//
+// The patterns are quasiquoted, `,x` is the fresh variable, that is the same for all
+// its occurrences in the pattern, unless `x` is already defined by the enclosing
+// fresh, or the arguments of the relation, then it refers to it. `_` matches anything.
+func compileMatche(args any, sc *scope, env *envir.Env) (goalCode, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	exprs, isList := matchedExprs(p.This)
+	var target term
+	if isList {
+		target = compileList(exprs, sc, env)
+	} else {
+		target = compileTerm(p.This, sc, env)
+	}
+	clauses, err := extractBranches(p.Next)
+	if err != nil {
+		return nil, err
+	}
+	var acc [][]goalCode
+	for _, expr := range clauses {
+		clause, ok := expr.(types.Pair)
+		if !ok {
+			return nil, NonList{expr}
+		}
+		if isList && !sameLength(exprs, unquasiquote(clause.This)) {
+			return nil, ArityError
+		}
+		code, err := compileClause(clause, target, sc, env)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, []goalCode{code})
+	}
+	return condCode{branches{clauses, acc, nil}, func(b branches) Goal { return Conde{b} }}, nil
+}
+
+// The list of the matched expressions, a single expression
+// is a symbol, a constant, or a quoted value
+func matchedExprs(arg any) (types.Pair, bool) {
+	p, ok := arg.(types.Pair)
+	if !ok || p.This == types.Symbol("quote") || p.This == types.Symbol("quasiquote") {
+		return types.Pair{}, false
+	}
+	return p, true
+}
+
+// The clause `(pattern g1 g2 ...)` is `(fresh (x ...) (== target pattern) g1 g2 ...)`
+func compileClause(clause types.Pair, target term, sc *scope, env *envir.Env) (goalCode, error) {
+	pattern := unquasiquote(clause.This)
+	var vars []types.Symbol
+	patternVars(pattern, sc, &vars)
+	local := &scope{vars, sc}
+	goals, err := compileGoals(clause.Next, local, env)
+	if err != nil {
+		return nil, err
+	}
+	// the target is evaluated in the scope enclosing the fresh variables
+	unify := primitive{newUnify, []term{enclosing{target}, compilePattern(pattern, local, new(int))}}
+	body := types.Pair{
+		This: types.List(types.Symbol("=="), types.Symbol("_"), types.List(types.Symbol("quasiquote"), pattern)),
+		Next: clause.Next,
+	}
+	return freshCode{vars, body, append([]goalCode{unify}, goals...), nil}, nil
+}
+
+// `lambdae` is the relation matching its arguments
+//
+//	(lambdae (args ...) ((pattern ...) g1 g2 ...) ...)
+//
+// is the same as
+//
+//	(lambda (args ...) (matche (args ...) ((pattern ...) g1 g2 ...) ...))
+func newLambdae(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	vars, rest, err := extractParams(p.This)
+	if err != nil {
+		return nil, err
+	}
+	fn := &Lambda{vars: vars, rest: rest, env: env}
+	names := symbolsList(fn.names())
+	fn.body = types.List(types.Cons(types.Symbol("matche"), names, p.Next))
+	fn.goal, err = compileMatche(types.Pair{This: names, Next: p.Next}, &scope{names: fn.names()}, env)
+	if err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
+
+// The term evaluated in the parent frame
+type enclosing struct {
+	term term
+}
+
+func (t enclosing) value(f *frame) (any, error) {
+	return t.term.value(f.parent)
+}
+
+// Compile the pattern to the term, where the variables are looked up in the scope of the clause,
+// the wildcards are the consecutive variables named `_`, as they were added by patternVars
+func compilePattern(pattern any, sc *scope, wildcards *int) term {
+	switch p := pattern.(type) {
+	case types.Symbol:
+		if p == "_" {
+			return wildcard(sc, wildcards)
+		}
+	case types.Pair:
+		if name, ok := patternVar(p); ok {
+			if name == "_" {
+				return wildcard(sc, wildcards)
+			}
+			depth, index, _ := sc.lookup(name)
+			return slot{depth, index}
+		}
+		return consTerm{compilePattern(p.This, sc, wildcards), compilePattern(p.Next, sc, wildcards)}
+	case *types.Vector:
+		var elems []term
+		for _, e := range p.Elems {
+			elems = append(elems, compilePattern(e, sc, wildcards))
+		}
+		return vectorTerm{elems}
+	}
+	return constant{pattern}
+}
+
+// The n-th wildcard in the scope
+func wildcard(sc *scope, n *int) term {
+	count := 0
+	for i, name := range sc.names {
+		if name != "_" {
+			continue
+		}
+		if count == *n {
+			*n++
+			return slot{0, i}
+		}
+		count++
+	}
+	return constant{nil}
+}
+
+// Collect the names of the new variables in the pattern, every wildcard is added separately
+func patternVars(pattern any, sc *scope, acc *[]types.Symbol) {
+	switch p := pattern.(type) {
+	case types.Symbol:
+		if p == "_" {
+			*acc = append(*acc, p)
+		}
+	case types.Pair:
+		if name, ok := patternVar(p); ok {
+			if name == "_" || !(contains(*acc, name) || sc.defines(name)) {
+				*acc = append(*acc, name)
+			}
+			return
+		}
+		patternVars(p.This, sc, acc)
+		patternVars(p.Next, sc, acc)
+	case *types.Vector:
+		for _, e := range p.Elems {
+			patternVars(e, sc, acc)
+		}
+	}
+}
+
+// The name `x` of the unquoted variable `,x`
+func patternVar(p types.Pair) (types.Symbol, bool) {
+	if p.This != types.Symbol("unquote") {
+		return "", false
+	}
+	args, ok := p.Next.(types.Pair)
+	if !ok || args.Next != nil {
+		return "", false
+	}
+	name, ok := args.This.(types.Symbol)
+	return name, ok
+}
+
+// The pattern can be explicitly quasiquoted
+func unquasiquote(pattern any) any {
+	if p, ok := pattern.(types.Pair); ok && p.This == types.Symbol("quasiquote") {
+		if args, ok := p.Next.(types.Pair); ok && args.Next == nil {
+			return args.This
+		}
+	}
+	return pattern
+}
+
+// Check if the pattern has as many elements as the list, the patterns
+// other than the proper lists can match the lists of any length
+func sameLength(list types.Pair, pattern any) bool {
+	n := 0
+	for head := pattern; head != nil; n++ {
+		p, ok := head.(types.Pair)
+		if !ok {
+			return true
+		}
+		if _, ok := patternVar(p); ok {
+			return true
+		}
+		head = p.Next
+	}
+	return n == list.Len()
+}
+
+func contains(names []types.Symbol, name types.Symbol) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
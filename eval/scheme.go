@@ -8,11 +8,28 @@ import (
 	"github.com/twolodzko/kanren/types"
 )
 
+// `define` procedure
+//
+//	(define name expr)
+//	(define (name args ...) body ...)
 func define(args any, env *envir.Env) (any, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
 		return nil, SyntaxError
 	}
+	if head, ok := p.This.(types.Pair); ok {
+		// (define (name . params) body ...) is (define name (lambda params body ...))
+		key, ok := head.This.(types.Symbol)
+		if !ok {
+			return nil, InvalidName{head.This}
+		}
+		val, err := newLambda(types.Pair{This: head.Next, Next: p.Next}, env)
+		if err != nil {
+			return nil, err
+		}
+		env.Set(key, val)
+		return val, nil
+	}
 	key, ok := p.This.(types.Symbol)
 	if !ok {
 		return nil, InvalidName{p.This}
@@ -29,95 +46,112 @@ func define(args any, env *envir.Env) (any, error) {
 	return val, nil
 }
 
-func car(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	v, err := Eval(p.This, env)
-	if err != nil {
-		return nil, err
+func car(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
 	}
-	switch p := v.(type) {
+	switch p := args[0].(type) {
 	case types.Pair:
 		return p.This, nil
 	default:
-		return nil, NonList{v}
+		return nil, NonList{args[0]}
 	}
 }
 
-func cdr(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	v, err := Eval(p.This, env)
-	if err != nil {
-		return nil, err
+func cdr(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
 	}
-	switch p := v.(type) {
+	switch p := args[0].(type) {
 	case types.Pair:
 		return p.Next, nil
 	default:
-		return nil, NonList{v}
+		return nil, NonList{args[0]}
 	}
 }
 
-func cons(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
+func cons(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, ArityError
 	}
-	a, b, err := evalTwo(p, env)
-	if err != nil {
-		return nil, err
+	return types.Cons(args[0], args[1]), nil
+}
+
+func list(args []any) (any, error) {
+	return types.List(args...), nil
+}
+
+func isNull(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
 	}
-	return types.Cons(a, b), nil
+	return args[0] == nil, nil
 }
 
-func list(args any, env *envir.Env) (any, error) {
-	if args == nil {
-		return nil, nil
+func isPair(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
 	}
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
+	_, ok := args[0].(types.Pair)
+	return ok, nil
+}
+
+// Call the procedure with the arguments, the last argument is the list
+// of the remaining arguments, the goals can be created the same way
+//
+//	(apply f arg ... list)
+func apply(args []any) (any, error) {
+	if len(args) < 2 {
+		return nil, ArityError
+	}
+	fn := args[0]
+	switch unwrap(fn).(type) {
+	case Procedure, *Lambda, goalForm:
+	default:
+		return nil, NotAProcedure{fn}
 	}
-	s, err := p.TryMap(func(val any) (any, error) {
-		return Eval(val, env)
+	vals := limited(args[1 : len(args)-1])
+	err := forEachElem(args[len(args)-1], func(val any) error {
+		vals = append(vals, val)
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	if len(s) == 0 {
-		return nil, nil
-	}
-	return types.Cons(s...), nil
+	return tailCall{fn, vals}, nil
 }
 
-func isNull(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	v, err := Eval(p.This, env)
-	if err != nil {
-		return nil, err
-	}
-	return v == nil, nil
+// The call made by apply, it is returned to the evaluator, so the tail calls
+// through apply do not grow the stack, and the goals are created in its env
+type tailCall struct {
+	fn   any
+	args []any
 }
 
-func isPair(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	v, err := Eval(p.This, env)
-	if err != nil {
-		return nil, err
+// Make the call, the calls returned by the procedures are followed
+func (c tailCall) apply(env *envir.Env) (any, error) {
+	for {
+		switch fn := unwrap(c.fn).(type) {
+		case Procedure:
+			val, err := fn(c.args)
+			call, ok := val.(tailCall)
+			if err != nil || !ok {
+				return val, err
+			}
+			c = call
+		case *Lambda:
+			return fn.apply(c.args)
+		case goalForm:
+			// the goal is created from the quoted values, as if they were written in its call
+			var acc []any
+			for _, v := range c.args {
+				acc = append(acc, types.List(types.Symbol("quote"), types.Freeze(v)))
+			}
+			return fn.eval(types.List(acc...), env)
+		default:
+			return nil, NotAProcedure{c.fn}
+		}
 	}
-	_, ok = v.(types.Pair)
-	return ok, nil
 }
 
 func and(args any, env *envir.Env) (any, error) {
@@ -162,16 +196,11 @@ func or(args any, env *envir.Env) (any, error) {
 	return false, nil
 }
 
-func not(args any, env *envir.Env) (any, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return nil, SyntaxError
-	}
-	val, err := Eval(p.This, env)
-	if err != nil {
-		return nil, err
+func not(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
 	}
-	return !types.IsTrue(val), nil
+	return !types.IsTrue(args[0]), nil
 }
 
 // `cond` procedure
@@ -208,6 +237,185 @@ func cond(args any, env *envir.Env) (any, *envir.Env, error) {
 	return nil, env, nil
 }
 
+// `set!` procedure, it changes the value of the already defined variable
+//
+//	(set! name expr)
+func set(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	key, ok := p.This.(types.Symbol)
+	if !ok {
+		return nil, InvalidName{p.This}
+	}
+	lhs, ok := p.Next.(types.Pair)
+	if !ok || lhs.Next != nil {
+		return nil, SyntaxError
+	}
+	local, key, ok := findEnv(key, env)
+	if !ok {
+		return nil, fmt.Errorf("unbound variable %v", key)
+	}
+	val, err := Eval(lhs.This, env)
+	if err != nil {
+		return nil, err
+	}
+	local.Set(key, val)
+	return nil, nil
+}
+
+// `begin` procedure
+//
+//	(begin expr1 expr2 ...)
+func begin(args any, env *envir.Env) (any, *envir.Env, error) {
+	return partialEval(args, env)
+}
+
+// `when` procedure
+//
+//	(when test expr1 expr2 ...)
+func when(args any, env *envir.Env) (any, *envir.Env, error) {
+	return conditional(args, env, true)
+}
+
+// `unless` procedure
+//
+//	(unless test expr1 expr2 ...)
+func unless(args any, env *envir.Env) (any, *envir.Env, error) {
+	return conditional(args, env, false)
+}
+
+// Evaluate the body when the result of the test is the expected one
+func conditional(args any, env *envir.Env, expected bool) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	test, err := Eval(p.This, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	if types.IsTrue(test) != expected {
+		return nil, env, nil
+	}
+	return partialEval(p.Next, env)
+}
+
+// `case` procedure
+//
+//	(case key ((datum1 datum2 ...) expr1 expr2 ...) ... (else expr1 expr2 ...))
+func caseForm(args any, env *envir.Env) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	key, err := Eval(p.This, env)
+	if err != nil {
+		return nil, nil, err
+	}
+	head := p.Next
+	for head != nil {
+		p, ok := head.(types.Pair)
+		if !ok {
+			return nil, nil, SyntaxError
+		}
+		clause, ok := p.This.(types.Pair)
+		if !ok {
+			return nil, nil, NonList{p.This}
+		}
+		if isKeyword(clause.This, "else") {
+			return partialEval(clause.Next, env)
+		}
+		found := false
+		err := forEachElem(clause.This, func(datum any) error {
+			found = found || reflect.DeepEqual(key, datum)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if found {
+			return partialEval(clause.Next, env)
+		}
+		head = p.Next
+	}
+	return nil, env, nil
+}
+
+// `do` procedure, the steps are evaluated after each iteration,
+// until the test is true, then the exprs are evaluated
+//
+//	(do ((var1 init1 step1) ...) (test expr1 expr2 ...) body1 body2 ...)
+func do(args any, env *envir.Env) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	next, ok := p.Next.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	exit, ok := next.This.(types.Pair)
+	if !ok {
+		return nil, nil, NonList{next.This}
+	}
+	var (
+		vars  []types.Symbol
+		steps []any
+	)
+	local := envir.NewEnvFrom(env)
+	err := forEachElem(p.This, func(val any) error {
+		b, ok := val.(types.Pair)
+		if !ok {
+			return NonList{val}
+		}
+		key, _, err := extractBinding(b)
+		if err != nil {
+			return err
+		}
+		if err := bind(b, local, env); err != nil {
+			return err
+		}
+		// the variable without the step keeps its value
+		var step any = key
+		if rest, ok := b.Next.(types.Pair).Next.(types.Pair); ok {
+			step = rest.This
+		}
+		vars = append(vars, key)
+		steps = append(steps, step)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		test, err := Eval(exit.This, local)
+		if err != nil {
+			return nil, nil, err
+		}
+		if types.IsTrue(test) {
+			return partialEval(exit.Next, local)
+		}
+		if err := forEachElem(next.Next, func(val any) error {
+			_, err := Eval(val, local)
+			return err
+		}); err != nil {
+			return nil, nil, err
+		}
+		// every iteration has its own bindings, as the closures could refer to them
+		iter := envir.NewEnvFrom(env)
+		for i, step := range steps {
+			val, err := Eval(step, local)
+			if err != nil {
+				return nil, nil, err
+			}
+			iter.Set(vars[i], val)
+		}
+		local = iter
+	}
+}
+
 func testCheck(args any, env *envir.Env) (any, error) {
 	p, ok := args.(types.Pair)
 	if !ok {
@@ -225,7 +433,8 @@ func testCheck(args any, env *envir.Env) (any, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !reflect.DeepEqual(a, b) {
+	// the vectors are compared regardless of whether they are constant
+	if !reflect.DeepEqual(types.Freeze(a), types.Freeze(b)) {
 		return nil, fmt.Errorf("test %s failed:\n        %v\n is not %v", tag, types.ToString(a), types.ToString(b))
 	}
 	return nil, nil
@@ -254,71 +463,36 @@ func load(args any, env *envir.Env) (any, error) {
 	return nil, nil
 }
 
-func cmp(args any, env *envir.Env, cmp func(a, b any) (bool, error)) (bool, error) {
-	var (
-		prev any
-		this any
-		err  error
-	)
-	p, ok := args.(types.Pair)
-	if !ok {
-		return false, SyntaxError
-	}
-	prev, err = Eval(p.This, env)
-	if err != nil {
-		return false, err
+func cmp(args []any, cmp func(a, b any) (bool, error)) (bool, error) {
+	if len(args) == 0 {
+		return false, ArityError
 	}
-	head := p.Next
-	for head != nil {
-		p, ok := head.(types.Pair)
-		if !ok {
-			return false, SyntaxError
-		}
-		this, err = Eval(p.This, env)
-		if err != nil {
-			return false, err
-		}
-		ok, err = cmp(prev, this)
+	for i := 1; i < len(args); i++ {
+		ok, err := cmp(args[i-1], args[i])
 		if !ok || err != nil {
-			return ok, nil
+			return ok, err
 		}
-		head = p.Next
 	}
 	return true, nil
 }
 
-func foldLeft(args any, env *envir.Env, fn func(acc, val int) (int, error)) (int, error) {
-	p, ok := args.(types.Pair)
-	if !ok {
-		return 0, SyntaxError
-	}
-	val, err := Eval(p.This, env)
-	if err != nil {
-		return 0, err
+func foldLeft(args []any, fn func(acc, val any) (any, error)) (any, error) {
+	if len(args) == 0 {
+		return 0, ArityError
 	}
-	acc, ok := val.(int)
-	if !ok {
-		return 0, NaN{val}
+	acc := args[0]
+	if !types.IsNumber(acc) {
+		return 0, NaN{acc}
 	}
-	head := p.Next
-	for head != nil {
-		p, ok := head.(types.Pair)
-		if !ok {
-			return 0, SyntaxError
-		}
-		val, err := Eval(p.This, env)
-		if err != nil {
-			return 0, err
-		}
-		this, ok := val.(int)
-		if !ok {
+	for _, val := range args[1:] {
+		if !types.IsNumber(val) {
 			return 0, NaN{val}
 		}
-		acc, err = fn(acc, this)
+		var err error
+		acc, err = fn(acc, val)
 		if err != nil {
-			return 0, nil
+			return 0, err
 		}
-		head = p.Next
 	}
 	return acc, nil
 }
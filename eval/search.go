@@ -0,0 +1,230 @@
+package eval
+
+import (
+	"fmt"
+)
+
+// Lazy stream of substitutions that is one of:
+//
+//   - nil for no answers (mzero),
+//   - *Stream for a single answer (unit),
+//   - Choice for an answer followed by the suspended rest of the stream,
+//   - Suspension for a stream that was not computed yet (inc),
+//   - Waiting for the consumers of tabled goals that wait for new answers.
+//
+// (see Byrd, 2009)
+type Answers = any
+
+// Delayed computation of a stream
+type Suspension func() (Answers, error)
+
+// An answer followed by the suspended rest of the stream
+type Choice struct {
+	answer *Stream
+	rest   Suspension
+}
+
+// The suspended consumers of the tabled goals, they are resumed
+// only when new answers were added to their tables
+type Waiting []waitingConsumer
+
+type waitingConsumer struct {
+	table  *table
+	seen   int
+	resume Suspension
+}
+
+// Resume the first consumer that has new answers, the others keep waiting,
+// return nil if no consumer has new answers, so the fixpoint was reached
+func (w Waiting) next() Suspension {
+	for i, c := range w {
+		if len(c.table.answers) == c.seen {
+			continue
+		}
+		rest := append(Waiting{}, w[:i]...)
+		rest = append(rest, w[i+1:]...)
+		return func() (Answers, error) {
+			a, err := c.resume()
+			if err != nil || len(rest) == 0 {
+				return a, err
+			}
+			return mplus(a, func() (Answers, error) {
+				return rest, nil
+			})
+		}
+	}
+	return nil
+}
+
+// Apply the function to the results of all the consumers when they are resumed
+func (w Waiting) then(fn func(Answers) (Answers, error)) Waiting {
+	acc := make(Waiting, len(w))
+	for i, c := range w {
+		resume := c.resume
+		c.resume = func() (Answers, error) {
+			a, err := resume()
+			if err != nil {
+				return nil, err
+			}
+			return fn(a)
+		}
+		acc[i] = c
+	}
+	return acc
+}
+
+// The consumers wait until all the other streams are exhausted
+func mplusWaiting(w Waiting, f Suspension) (Answers, error) {
+	return Suspension(func() (Answers, error) {
+		b, err := f()
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := b.(Waiting); ok {
+			return append(append(Waiting{}, w...), v...), nil
+		}
+		return mplus(b, func() (Answers, error) {
+			return w, nil
+		})
+	}), nil
+}
+
+// Merge the streams, interleaving their answers (see Byrd, 2009)
+func mplus(a Answers, f Suspension) (Answers, error) {
+	switch a := a.(type) {
+	case nil:
+		return f()
+	case Waiting:
+		return mplusWaiting(a, f)
+	case Suspension:
+		return Suspension(func() (Answers, error) {
+			b, err := f()
+			if err != nil {
+				return nil, err
+			}
+			// swap the streams, so the other one is forced next
+			return mplus(b, a)
+		}), nil
+	case *Stream:
+		return Choice{a, f}, nil
+	case Choice:
+		return Choice{a.answer, func() (Answers, error) {
+			b, err := f()
+			if err != nil {
+				return nil, err
+			}
+			return mplus(b, a.rest)
+		}}, nil
+	default:
+		return nil, fmt.Errorf("invalid stream: %v", a)
+	}
+}
+
+// Concatenate the streams, without interleaving them
+func mappend(a Answers, f Suspension) (Answers, error) {
+	switch a := a.(type) {
+	case nil:
+		return f()
+	case Waiting:
+		return mplusWaiting(a, f)
+	case Suspension:
+		return Suspension(func() (Answers, error) {
+			b, err := a()
+			if err != nil {
+				return nil, err
+			}
+			return mappend(b, f)
+		}), nil
+	case *Stream:
+		return Choice{a, f}, nil
+	case Choice:
+		return Choice{a.answer, func() (Answers, error) {
+			b, err := a.rest()
+			if err != nil {
+				return nil, err
+			}
+			return mappend(b, f)
+		}}, nil
+	default:
+		return nil, fmt.Errorf("invalid stream: %v", a)
+	}
+}
+
+// Apply the goal to every answer in the stream (see Byrd, 2009)
+func bindGoal(a Answers, g Goal) (Answers, error) {
+	switch a := a.(type) {
+	case nil:
+		return nil, nil
+	case Suspension:
+		return Suspension(func() (Answers, error) {
+			b, err := a()
+			if err != nil {
+				return nil, err
+			}
+			return bindGoal(b, g)
+		}), nil
+	case *Stream:
+		return query(g, a)
+	case Waiting:
+		return a.then(func(b Answers) (Answers, error) {
+			return bindGoal(b, g)
+		}), nil
+	case Choice:
+		b, err := query(g, a.answer)
+		if err != nil {
+			return nil, err
+		}
+		return mplus(b, func() (Answers, error) {
+			c, err := a.rest()
+			if err != nil {
+				return nil, err
+			}
+			return bindGoal(c, g)
+		})
+	default:
+		return nil, fmt.Errorf("invalid stream: %v", a)
+	}
+}
+
+// Force the stream until n answers are found, or until it is exhausted when n < 0
+// (see Byrd, 2009)
+func take(n int, f Suspension) ([]*Stream, error) {
+	var acc []*Stream
+	for f != nil && (n < 0 || len(acc) < n) {
+		s, rest, err := pull(f)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			acc = append(acc, s)
+		}
+		f = rest
+	}
+	return acc, nil
+}
+
+// Force the stream until the next answer is found, return it with the suspended
+// rest of the stream, the answer is nil when the stream is exhausted
+func pull(f Suspension) (*Stream, Suspension, error) {
+	for f != nil {
+		a, err := f()
+		if err != nil {
+			return nil, nil, err
+		}
+		switch a := a.(type) {
+		case nil:
+			return nil, nil, nil
+		case Suspension:
+			f = a
+		case *Stream:
+			return a, nil, nil
+		case Choice:
+			return a.answer, a.rest, nil
+		case Waiting:
+			f = a.next()
+		default:
+			return nil, nil, fmt.Errorf("invalid stream: %v", a)
+		}
+	}
+	return nil, nil, nil
+}
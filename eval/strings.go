@@ -0,0 +1,276 @@
+package eval
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+func toString(val any) (string, error) {
+	s, ok := val.(string)
+	if !ok {
+		return "", WrongArg{val}
+	}
+	return s, nil
+}
+
+func toChar(val any) (types.Char, error) {
+	c, ok := val.(types.Char)
+	if !ok {
+		return 0, WrongArg{val}
+	}
+	return c, nil
+}
+
+// The index in the bounds lo to hi
+func toIndex(val any, lo, hi int) (int, error) {
+	i, ok := val.(int)
+	if !ok || i < lo || i > hi {
+		return 0, WrongArg{val}
+	}
+	return i, nil
+}
+
+// The procedure checking the type of its argument
+func isType[T any](args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	_, ok := args[0].(T)
+	return ok, nil
+}
+
+// The number of the characters in the string
+//
+//	(string-length s)
+func stringLength(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return len([]rune(s)), nil
+}
+
+// The k-th character of the string
+//
+//	(string-ref s k)
+func stringRef(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, ArityError
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	k, err := toIndex(args[1], 0, len(runes)-1)
+	if err != nil {
+		return nil, err
+	}
+	return types.Char(runes[k]), nil
+}
+
+// The part of the string from start to end, or to the end of the string
+//
+//	(substring s start)
+//	(substring s start end)
+func substring(args []any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, ArityError
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, err := toIndex(args[1], 0, len(runes))
+	if err != nil {
+		return nil, err
+	}
+	end := len(runes)
+	if len(args) == 3 {
+		end, err = toIndex(args[2], start, len(runes))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+// Join the strings
+//
+//	(string-append s ...)
+func stringAppend(args []any) (any, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		s, err := toString(arg)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s)
+	}
+	return b.String(), nil
+}
+
+// The list of the characters of the string
+//
+//	(string->list s)
+func stringToList(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var acc []any
+	for _, r := range s {
+		acc = append(acc, types.Char(r))
+	}
+	return types.List(acc...), nil
+}
+
+// The string made of the list of the characters
+//
+//	(list->string l)
+func listToString(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	var b strings.Builder
+	err := forEachElem(args[0], func(val any) error {
+		c, err := toChar(val)
+		if err != nil {
+			return err
+		}
+		b.WriteRune(rune(c))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.String(), nil
+}
+
+// The symbol named by the string
+//
+//	(string->symbol s)
+func stringToSymbol(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.Symbol(s), nil
+}
+
+// The name of the symbol
+//
+//	(symbol->string sym)
+func symbolToString(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	sym, ok := args[0].(types.Symbol)
+	if !ok {
+		return nil, WrongArg{args[0]}
+	}
+	return string(sym), nil
+}
+
+// Compare the strings, `(string=? s1 s2 ...)`, `(string<? s1 s2 ...)`, etc
+func compareStrings(cmp func(a, b string) bool) Procedure {
+	return func(args []any) (any, error) {
+		return cmpAll(args, toString, cmp)
+	}
+}
+
+// Compare the characters, `(char=? c1 c2 ...)`, `(char<? c1 c2 ...)`, etc
+func compareChars(cmp func(a, b types.Char) bool) Procedure {
+	return func(args []any) (any, error) {
+		return cmpAll(args, toChar, cmp)
+	}
+}
+
+// Check if all the consecutive arguments are ordered, all of them are type checked
+func cmpAll[T any](args []any, convert func(any) (T, error), cmp func(a, b T) bool) (bool, error) {
+	if len(args) == 0 {
+		return false, ArityError
+	}
+	var vals []T
+	for _, arg := range args {
+		v, err := convert(arg)
+		if err != nil {
+			return false, err
+		}
+		vals = append(vals, v)
+	}
+	for i := 1; i < len(vals); i++ {
+		if !cmp(vals[i-1], vals[i]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// The predicate for the character, `(char-alphabetic? c)`, etc
+func charPredicate(fn func(r rune) bool) Procedure {
+	return func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, ArityError
+		}
+		c, err := toChar(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return fn(rune(c)), nil
+	}
+}
+
+// Map the character, `(char-upcase c)` and `(char-downcase c)`
+func charMap(fn func(r rune) rune) Procedure {
+	return func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, ArityError
+		}
+		c, err := toChar(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return types.Char(fn(rune(c))), nil
+	}
+}
+
+// The Unicode code point of the character
+//
+//	(char->integer c)
+func charToInteger(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	c, err := toChar(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return int(c), nil
+}
+
+// The character for the Unicode code point
+//
+//	(integer->char n)
+func integerToChar(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	n, err := toIndex(args[0], 0, unicode.MaxRune)
+	if err != nil {
+		return nil, err
+	}
+	return types.Char(n), nil
+}
@@ -0,0 +1,140 @@
+package eval
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+const (
+	trieBits  = 4
+	trieWidth = 1 << trieBits
+)
+
+// Persistent map from the variables to their values, implemented as
+// a hash array mapped trie indexed by the ids of the variables. Setting
+// a value copies only the path to it, so the previous versions of the map
+// stay unchanged and share the rest of the trie, the lookups take
+// O(log n) time.
+type substitution struct {
+	root *trieNode
+	size int
+}
+
+// The node holding the entries and the child nodes, only the non-empty
+// slots are stored, the bitmap marks which of them are used
+type trieNode struct {
+	bitmap uint32
+	slots  []trieSlot
+}
+
+// Entry for the key, or the child node when the node is not nil
+type trieSlot struct {
+	key  types.Variable
+	val  any
+	node *trieNode
+}
+
+func (s substitution) get(key types.Variable) (any, bool) {
+	id := key.Id()
+	n := s.root
+	for shift := uint(0); n != nil; shift += trieBits {
+		bit := uint32(1) << ((id >> shift) % trieWidth)
+		if n.bitmap&bit == 0 {
+			return nil, false
+		}
+		slot := n.slots[n.index(bit)]
+		if slot.node == nil {
+			if slot.key == key {
+				return slot.val, true
+			}
+			return nil, false
+		}
+		n = slot.node
+	}
+	return nil, false
+}
+
+// Return the new map with the value set for the key
+func (s substitution) set(key types.Variable, val any) substitution {
+	root, added := s.root.set(key, val, 0)
+	if added {
+		s.size++
+	}
+	s.root = root
+	return s
+}
+
+func (s substitution) len() int {
+	return s.size
+}
+
+// Check if it is the same version of the map, setting a value always
+// creates a new version
+func (s substitution) same(other substitution) bool {
+	return s.root == other.root
+}
+
+// All the entries, ordered by the creation time of the variables
+func (s substitution) entries() []KeyVal {
+	var acc []KeyVal
+	s.root.collect(&acc)
+	sort.Slice(acc, func(i, j int) bool {
+		return acc[i].key.Id() < acc[j].key.Id()
+	})
+	return acc
+}
+
+// The position of the slot in the compressed slice
+func (n *trieNode) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *trieNode) set(key types.Variable, val any, shift uint) (*trieNode, bool) {
+	if n == nil {
+		n = &trieNode{}
+	}
+	bit := uint32(1) << ((key.Id() >> shift) % trieWidth)
+	i := n.index(bit)
+
+	if n.bitmap&bit == 0 {
+		slots := make([]trieSlot, len(n.slots)+1)
+		copy(slots, n.slots[:i])
+		slots[i] = trieSlot{key: key, val: val}
+		copy(slots[i+1:], n.slots[i:])
+		return &trieNode{n.bitmap | bit, slots}, true
+	}
+
+	slot := n.slots[i]
+	var added bool
+	switch {
+	case slot.node != nil:
+		slot.node, added = slot.node.set(key, val, shift+trieBits)
+	case slot.key == key:
+		slot.val = val
+	default:
+		// move both entries one level down
+		child, _ := (*trieNode)(nil).set(slot.key, slot.val, shift+trieBits)
+		child, _ = child.set(key, val, shift+trieBits)
+		slot = trieSlot{node: child}
+		added = true
+	}
+	slots := make([]trieSlot, len(n.slots))
+	copy(slots, n.slots)
+	slots[i] = slot
+	return &trieNode{n.bitmap, slots}, added
+}
+
+func (n *trieNode) collect(acc *[]KeyVal) {
+	if n == nil {
+		return
+	}
+	for _, slot := range n.slots {
+		if slot.node != nil {
+			slot.node.collect(acc)
+		} else {
+			*acc = append(*acc, KeyVal{slot.key, slot.val})
+		}
+	}
+}
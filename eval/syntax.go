@@ -0,0 +1,532 @@
+package eval
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/types"
+)
+
+// The macro defined with `syntax-rules`, the form is expanded using the template
+// of the first rule whose pattern matches it, and the expansion is evaluated instead
+// of the form. The symbols introduced by the template are renamed, so they cannot
+// capture the names used in the arguments of the macro, and the renamed symbols
+// that are not bound by the expansion refer to the definitions from the env of the macro.
+type Macro struct {
+	// the unique id of the macro, and the number of its expansions,
+	// they are a part of the renamed names
+	id, expansions int
+	ellipsis       types.Symbol
+	literals       []types.Symbol
+	rules          []syntaxRule
+	env            *envir.Env
+}
+
+type syntaxRule struct {
+	pattern, template any
+}
+
+// The values of the pattern variables
+type bindings map[types.Symbol]any
+
+// The values matched by the pattern followed by the ellipsis
+type sequence []any
+
+var macros atomic.Int64
+
+// `syntax-rules` procedure
+//
+//	(syntax-rules (literal ...) (pattern template) ...)
+//	(syntax-rules ellipsis (literal ...) (pattern template) ...)
+func syntaxRules(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	m := &Macro{id: int(macros.Add(1)), ellipsis: "...", env: env}
+	if name, ok := p.This.(types.Symbol); ok {
+		m.ellipsis = name
+		if p, ok = p.Next.(types.Pair); !ok {
+			return nil, SyntaxError
+		}
+	}
+	err := forEachElem(p.This, func(val any) error {
+		name, ok := val.(types.Symbol)
+		if !ok {
+			return InvalidName{val}
+		}
+		m.literals = append(m.literals, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = forEachElem(p.Next, func(val any) error {
+		r, ok := val.(types.Pair)
+		if !ok {
+			return NonList{val}
+		}
+		if _, ok := r.This.(types.Pair); !ok {
+			return SyntaxError
+		}
+		t, ok := r.Next.(types.Pair)
+		if !ok || t.Next != nil {
+			return SyntaxError
+		}
+		m.rules = append(m.rules, syntaxRule{r.This, t.This})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// `define-syntax` procedure
+//
+//	(define-syntax name (syntax-rules ...))
+func defineSyntax(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	name, ok := p.This.(types.Symbol)
+	if !ok {
+		return nil, InvalidName{p.This}
+	}
+	spec, ok := p.Next.(types.Pair)
+	if !ok || spec.Next != nil {
+		return nil, SyntaxError
+	}
+	m, err := evalMacro(spec.This, env)
+	if err != nil {
+		return nil, err
+	}
+	env.Set(name, m)
+	return m, nil
+}
+
+// `let-syntax` procedure, the macros are defined in the env enclosing it
+//
+//	(let-syntax ((name (syntax-rules ...)) ...) expr1 expr2 ...)
+func letSyntax(args any, env *envir.Env) (any, *envir.Env, error) {
+	return localSyntax(args, env, false)
+}
+
+// `letrec-syntax` procedure, the macros can refer to each other
+//
+//	(letrec-syntax ((name (syntax-rules ...)) ...) expr1 expr2 ...)
+func letrecSyntax(args any, env *envir.Env) (any, *envir.Env, error) {
+	return localSyntax(args, env, true)
+}
+
+func localSyntax(args any, env *envir.Env, recursive bool) (any, *envir.Env, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, nil, SyntaxError
+	}
+	local := envir.NewEnvFrom(env)
+	parent := env
+	if recursive {
+		parent = local
+	}
+	err := forEachElem(p.This, func(val any) error {
+		b, ok := val.(types.Pair)
+		if !ok {
+			return NonList{val}
+		}
+		name, spec, err := extractBinding(b)
+		if err != nil {
+			return err
+		}
+		m, err := evalMacro(spec, parent)
+		if err != nil {
+			return err
+		}
+		local.Set(name, m)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return partialEval(p.Next, local)
+}
+
+func evalMacro(spec any, env *envir.Env) (*Macro, error) {
+	val, err := Eval(spec, env)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := val.(*Macro)
+	if !ok {
+		return nil, WrongArg{val}
+	}
+	return m, nil
+}
+
+// Expand the macro call in the env, the macro is bound in it under its key, unless it is
+// already visible there, so the symbols renamed by the macro can find the env of the macro
+func (m *Macro) expandIn(form types.Pair, env *envir.Env) (any, error) {
+	key := macroKey(m.id)
+	if _, ok := env.Get(key); !ok {
+		env.Set(key, m)
+	}
+	return m.expand(form)
+}
+
+// Expand the macro call using the first matching rule
+func (m *Macro) expand(form types.Pair) (any, error) {
+	for _, r := range m.rules {
+		b := make(bindings)
+		// the keyword of the macro is not matched
+		if m.match(r.pattern.(types.Pair).Next, form.Next, b) {
+			m.expansions++
+			e := expansion{m, m.expansions, make(map[types.Symbol]types.Symbol)}
+			return e.expand(r.template, b, 0)
+		}
+	}
+	return nil, fmt.Errorf("no syntax rule matches %v", types.ToString(form))
+}
+
+func (m *Macro) String() string {
+	return "<macro>"
+}
+
+func (m *Macro) isLiteral(name types.Symbol) bool {
+	for _, l := range m.literals {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Match the form with the pattern, setting the bindings of the pattern variables
+func (m *Macro) match(pattern, form any, b bindings) bool {
+	switch p := pattern.(type) {
+	case types.Symbol:
+		switch {
+		case p == "_":
+			return true
+		case m.isLiteral(p):
+			name, ok := form.(types.Symbol)
+			return ok && original(name) == p
+		}
+		b[p] = form
+		return true
+	case types.Pair:
+		if next, ok := p.Next.(types.Pair); ok && next.This == m.ellipsis {
+			return m.matchEllipsis(p.This, next.Next, form, b)
+		}
+		f, ok := form.(types.Pair)
+		return ok && m.match(p.This, f.This, b) && m.match(p.Next, f.Next, b)
+	case *types.Vector:
+		// the elements are matched like the lists
+		f, ok := form.(*types.Vector)
+		return ok && m.match(types.List(p.Elems...), types.List(f.Elems...), b)
+	case nil:
+		return form == nil
+	default:
+		return reflect.DeepEqual(pattern, form)
+	}
+}
+
+// Match the elements of the form with the pattern followed by the ellipsis,
+// leaving as many elements as needed for the rest of the pattern
+func (m *Macro) matchEllipsis(pattern, rest, form any, b bindings) bool {
+	n := length(form) - length(rest)
+	if n < 0 {
+		return false
+	}
+	vars := m.patternVars(pattern, nil)
+	seqs := make([]sequence, len(vars))
+	head := form
+	for range n {
+		p := head.(types.Pair)
+		local := make(bindings)
+		if !m.match(pattern, p.This, local) {
+			return false
+		}
+		for i, v := range vars {
+			seqs[i] = append(seqs[i], local[v])
+		}
+		head = p.Next
+	}
+	for i, v := range vars {
+		b[v] = seqs[i]
+	}
+	return m.match(rest, head, b)
+}
+
+func (m *Macro) patternVars(pattern any, acc []types.Symbol) []types.Symbol {
+	switch p := pattern.(type) {
+	case types.Symbol:
+		if p != "_" && p != m.ellipsis && !m.isLiteral(p) {
+			acc = append(acc, p)
+		}
+	case types.Pair:
+		acc = m.patternVars(p.This, acc)
+		acc = m.patternVars(p.Next, acc)
+	case *types.Vector:
+		for _, e := range p.Elems {
+			acc = m.patternVars(e, acc)
+		}
+	}
+	return acc
+}
+
+// The number of the pairs in the list
+func length(val any) int {
+	n := 0
+	for {
+		p, ok := val.(types.Pair)
+		if !ok {
+			return n
+		}
+		val = p.Next
+		n++
+	}
+}
+
+// Single expansion of the macro, the renamed symbols are shared
+// by all the occurrences in the template
+type expansion struct {
+	macro   *Macro
+	id      int
+	renames map[types.Symbol]types.Symbol
+}
+
+// Fill the template with the values of the pattern variables, the quoted
+// is the quasiquote depth, or < 0 inside the quote, the quoted symbols
+// are not renamed
+func (e expansion) expand(template any, b bindings, quoted int) (any, error) {
+	switch t := template.(type) {
+	case types.Symbol:
+		if val, ok := b[t]; ok {
+			if _, ok := val.(sequence); ok {
+				return nil, fmt.Errorf("%v is used without the ellipsis", t)
+			}
+			return val, nil
+		}
+		if quoted != 0 {
+			return t, nil
+		}
+		return e.rename(t), nil
+	case types.Pair:
+		if sym, ok := t.This.(types.Symbol); ok {
+			if _, ok := b[sym]; !ok {
+				quoted = quoteDepth(sym, quoted)
+			}
+		}
+		if next, ok := t.Next.(types.Pair); ok && next.This == e.macro.ellipsis {
+			depth, rest := 1, next.Next
+			for {
+				r, ok := rest.(types.Pair)
+				if !ok || r.This != e.macro.ellipsis {
+					break
+				}
+				depth++
+				rest = r.Next
+			}
+			elems, err := e.expandEllipsis(t.This, depth, b, quoted)
+			if err != nil {
+				return nil, err
+			}
+			tail, err := e.expand(rest, b, quoted)
+			if err != nil {
+				return nil, err
+			}
+			if len(elems) == 0 {
+				return tail, nil
+			}
+			return types.Cons(append(elems, tail)...), nil
+		}
+		head, err := e.expand(t.This, b, quoted)
+		if err != nil {
+			return nil, err
+		}
+		tail, err := e.expand(t.Next, b, quoted)
+		if err != nil {
+			return nil, err
+		}
+		return types.Pair{This: head, Next: tail}, nil
+	case *types.Vector:
+		// the vector is a literal, so its symbols are not renamed,
+		// unless they are unquoted in the quasiquote
+		if quoted == 0 {
+			quoted = -1
+		}
+		val, err := e.expand(types.List(t.Elems...), b, quoted)
+		if err != nil {
+			return nil, err
+		}
+		var elems []any
+		err = forEachElem(val, func(v any) error {
+			elems = append(elems, v)
+			return nil
+		})
+		return &types.Vector{Elems: elems, Const: true}, err
+	default:
+		return template, nil
+	}
+}
+
+// Fill the template followed by depth ellipses for each of the matched values
+func (e expansion) expandEllipsis(template any, depth int, b bindings, quoted int) ([]any, error) {
+	if depth == 0 {
+		val, err := e.expand(template, b, quoted)
+		if err != nil {
+			return nil, err
+		}
+		return []any{val}, nil
+	}
+	var vars []types.Symbol
+	n := -1
+	for _, v := range e.macro.patternVars(template, nil) {
+		seq, ok := b[v].(sequence)
+		if !ok {
+			continue
+		}
+		if n >= 0 && len(seq) != n {
+			return nil, fmt.Errorf("pattern variables in %v matched different numbers of values", types.ToString(template))
+		}
+		n = len(seq)
+		vars = append(vars, v)
+	}
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("no pattern variables to repeat in %v", types.ToString(template))
+	}
+	var acc []any
+	for i := range n {
+		local := make(bindings, len(b))
+		for k, v := range b {
+			local[k] = v
+		}
+		for _, v := range vars {
+			local[v] = b[v].(sequence)[i]
+		}
+		vals, err := e.expandEllipsis(template, depth-1, local, quoted)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, vals...)
+	}
+	return acc, nil
+}
+
+// The symbol introduced by the template gets the unique name, the ellipsis,
+// the wildcard, and the quotes are not renamed, so they can be used in the expansion
+func (e expansion) rename(name types.Symbol) types.Symbol {
+	switch name {
+	case e.macro.ellipsis, "_", "quote", "quasiquote", "unquote":
+		return name
+	}
+	if alias, ok := e.renames[name]; ok {
+		return alias
+	}
+	alias := types.Symbol(fmt.Sprintf("%s%s%d.%d", name, aliasSep, e.macro.id, e.id))
+	e.renames[name] = alias
+	return alias
+}
+
+// The quasiquote depth inside the form starting with the symbol
+func quoteDepth(sym types.Symbol, quoted int) int {
+	switch {
+	case sym == "quote" && quoted == 0:
+		return -1
+	case sym == "quasiquote" && quoted >= 0:
+		return quoted + 1
+	case sym == "unquote" && quoted > 0:
+		return quoted - 1
+	}
+	return quoted
+}
+
+const aliasSep = "·"
+
+// The name before it was renamed by the macro and the env of the macro,
+// the macro is found in the env under its key
+func unalias(name types.Symbol, env *envir.Env) (types.Symbol, *envir.Env, bool) {
+	i := strings.LastIndex(string(name), aliasSep)
+	if i < 0 {
+		return name, nil, false
+	}
+	var id, n int
+	if _, err := fmt.Sscanf(string(name[i+len(aliasSep):]), "%d.%d", &id, &n); err != nil {
+		return name, nil, false
+	}
+	val, _ := env.Get(macroKey(id))
+	m, ok := val.(*Macro)
+	if !ok {
+		return name, nil, false
+	}
+	return name[:i], m.env, true
+}
+
+// The name under which the macro is bound in the envs where it was expanded
+func macroKey(id int) types.Symbol {
+	return types.Symbol(fmt.Sprintf("%s%d", aliasSep, id))
+}
+
+// The name as it was written, before it was renamed by the macros
+func original(name types.Symbol) types.Symbol {
+	if i := strings.Index(string(name), aliasSep); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// Check if the value is the symbol, that could be renamed by the macro
+func isKeyword(val any, name types.Symbol) bool {
+	sym, ok := val.(types.Symbol)
+	return ok && original(sym) == name
+}
+
+// Find the value of the symbol, the names renamed by the macros,
+// if not bound by the expansion, are looked up in the env of the macro
+func lookup(name types.Symbol, env *envir.Env) (any, bool) {
+	if val, ok := env.Get(name); ok {
+		return val, true
+	}
+	if orig, env, ok := unalias(name, env); ok {
+		return lookup(orig, env)
+	}
+	return nil, false
+}
+
+// Find the env where the symbol is defined, as in lookup
+func findEnv(name types.Symbol, env *envir.Env) (*envir.Env, types.Symbol, bool) {
+	if local, ok := env.FindEnv(name); ok {
+		return local, name, true
+	}
+	if orig, env, ok := unalias(name, env); ok {
+		return findEnv(orig, env)
+	}
+	return nil, name, false
+}
+
+// Expand the macro calls at the head of the expression
+func expandMacros(expr any, sc *scope, env *envir.Env) (any, error) {
+	for {
+		p, ok := expr.(types.Pair)
+		if !ok {
+			return expr, nil
+		}
+		name, ok := p.This.(types.Symbol)
+		if !ok || sc.defines(name) {
+			return expr, nil
+		}
+		val, _ := lookup(name, env)
+		m, ok := val.(*Macro)
+		if !ok {
+			return expr, nil
+		}
+		var err error
+		if expr, err = m.expandIn(p, env); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/twolodzko/kanren/envir"
+	"github.com/twolodzko/kanren/types"
+)
+
+// The tables of the tabled relations, shared by all the streams of a single run,
+// for each relation the answers are memoized per variant of the call arguments
+type tables map[*Tabled]map[string]*table
+
+// The answers found so far for the call
+type table struct {
+	answers []any
+	seen    map[string]bool
+}
+
+// Add the answer if it is not a variant of the answers already in the table
+func (t *table) add(answer any) bool {
+	key := types.ToString(answer)
+	if t.seen[key] {
+		return false
+	}
+	t.seen[key] = true
+	t.answers = append(t.answers, answer)
+	return true
+}
+
+// Consume the answers from the table starting from the i-th one, when all
+// of them were consumed, wait until the producer adds new answers
+// (reuse in Byrd, 2009)
+func (t *table) reuse(args any, s *Stream, i int) (Answers, error) {
+	if i == len(t.answers) {
+		return Waiting{{t, i, func() (Answers, error) {
+			return t.reuse(args, s, i)
+		}}}, nil
+	}
+	var a Answers
+	u := s.fork()
+	if u.unifyVerify(args, rename(t.answers[i], make(map[types.Free]types.Variable))) {
+		a = u
+	}
+	return mplus(a, func() (Answers, error) {
+		return t.reuse(args, s, i+1)
+	})
+}
+
+// Replace the reified variables with new variables
+func rename(v any, vars map[types.Free]types.Variable) any {
+	switch v := v.(type) {
+	case types.Free:
+		x, ok := vars[v]
+		if !ok {
+			x = types.NewVariable(v.String())
+			vars[v] = x
+		}
+		return x
+	case types.Pair:
+		return types.Cons(v.Map(func(x any) any {
+			return rename(x, vars)
+		})...)
+	case *types.Vector:
+		return v.Map(func(x any) any {
+			return rename(x, vars)
+		})
+	default:
+		return v
+	}
+}
+
+// The value with the unbound variables replaced by their reified names,
+// so the values that are the same up to the names of the variables are equal
+func (s Stream) variant(v any) any {
+	v = s.deepWalk(v)
+	r := NewStream()
+	r.debug = s.debug
+	r.reifyStream(v)
+	return r.deepWalk(v)
+}
+
+// The key of the call is the variant of the arguments together with the constraints
+// on their variables, so the calls made under different constraints do not share
+// the answers, that were filtered by the constraints
+func (s Stream) callKey(args any) string {
+	v := s.deepWalk(args)
+	r := NewStream()
+	r.reifyStream(v)
+	// the constraint is relevant if it mentions any variable of the arguments
+	relevant := func(vals ...any) bool {
+		for _, x := range vals {
+			if hasFree(r.deepWalk(s.deepWalk(x))) {
+				return true
+			}
+		}
+		return false
+	}
+	// the other variables of the constraints are reified as well
+	reified := func(vals ...any) any {
+		var acc []any
+		for _, x := range vals {
+			x = s.deepWalk(x)
+			r.reifyStream(x)
+			acc = append(acc, r.deepWalk(x))
+		}
+		return types.List(acc...)
+	}
+	acc := []any{r.deepWalk(v)}
+	for _, p := range s.diseqs {
+		if relevant(p.pairs()...) {
+			acc = append(acc, types.Cons(types.Symbol("=/="), reified(p.pairs()...)))
+		}
+	}
+	for _, kv := range s.typed {
+		if relevant(kv.key) {
+			acc = append(acc, types.Cons(types.Symbol(kv.val.(string)), reified(kv.key)))
+		}
+	}
+	for _, kv := range s.absents {
+		if relevant(kv.key) {
+			acc = append(acc, types.Cons(types.Symbol("absento"), reified(kv.val, kv.key)))
+		}
+	}
+	for _, x := range s.domainVars(v, nil) {
+		d, _ := s.domain(x)
+		acc = append(acc, types.List(types.Symbol("dom"), r.deepWalk(x), d.String()))
+	}
+	for _, c := range s.fdcs {
+		if relevant(c.args...) {
+			acc = append(acc, types.Cons(types.Symbol(c.op), reified(c.args...)))
+		}
+	}
+	return types.ToString(types.List(acc...))
+}
+
+// Check if the value contains any reified variables
+func hasFree(v any) bool {
+	switch v := v.(type) {
+	case types.Free:
+		return true
+	case types.Pair:
+		return v.Any(hasFree)
+	case *types.Vector:
+		return v.Any(hasFree)
+	case types.Labelled:
+		return hasFree(v.Value)
+	default:
+		return false
+	}
+}
+
+// Relation that memoizes its answers, so it terminates for the left-recursive
+// definitions and the cycles in the data (see Byrd, 2009)
+//
+//	(tabled (args ...) g1 g2 ...)
+type Tabled struct {
+	vars  []types.Symbol
+	body  types.Pair
+	goals []goalCode
+	env   *envir.Env
+}
+
+func newTabled(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	var vars []types.Symbol
+	switch a := p.This.(type) {
+	case types.Pair:
+		var err error
+		vars, err = extractSymbols(a)
+		if err != nil {
+			return nil, err
+		}
+	case nil:
+	default:
+		return nil, NonList{p.This}
+	}
+	body, ok := p.Next.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	goals, err := compileGoals(body, &scope{names: vars}, env)
+	if err != nil {
+		return nil, err
+	}
+	return (&Tabled{vars, body, goals, env}).relation("tabled"), nil
+}
+
+// Define the tabled relation
+//
+//	(defrel-tabled (name args ...) g1 g2 ...)
+func defrelTabled(args any, env *envir.Env) (any, error) {
+	p, ok := args.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	head, ok := p.This.(types.Pair)
+	if !ok {
+		return nil, SyntaxError
+	}
+	name, ok := head.This.(types.Symbol)
+	if !ok {
+		return nil, InvalidName{head.This}
+	}
+	rel, err := newTabled(types.Cons(head.Next, p.Next), env)
+	if err != nil {
+		return nil, err
+	}
+	// print it with the name of the relation, not the anonymous one
+	rel.(*builtin).name = string(name)
+	env.Set(name, rel)
+	return rel, nil
+}
+
+// The procedure that returns the tabled goal for the arguments,
+// it is printed with the name, e.g. `#<procedure patho>`
+func (rel *Tabled) relation(name string) *builtin {
+	return &builtin{name, Procedure(func(vals []any) (any, error) {
+		if len(vals) != len(rel.vars) {
+			return nil, ArityError
+		}
+		vals = freezeAll(vals)
+		return TabledCall{rel, types.List(vals...), &frame{names: rel.vars, vals: vals, env: rel.env}}, nil
+	})}
+}
+
+type TabledCall struct {
+	rel   *Tabled
+	args  any
+	frame *frame
+}
+
+func (g TabledCall) Query(s *Stream) (Answers, error) {
+	if s.tables == nil {
+		return nil, errors.New("tabled relation was used outside of run")
+	}
+	calls, ok := s.tables[g.rel]
+	if !ok {
+		calls = make(map[string]*table)
+		s.tables[g.rel] = calls
+	}
+	key := s.callKey(g.args)
+	if t, ok := calls[key]; ok {
+		// the call was already made, so consume its answers
+		return t.reuse(g.args, s, 0)
+	}
+	t := &table{seen: make(map[string]bool)}
+	calls[key] = t
+	goals, err := instantiateAll(g.rel.goals, g.frame)
+	if err != nil {
+		return nil, err
+	}
+	a, err := queryAll(goals, s)
+	if err != nil {
+		return nil, err
+	}
+	return bindGoal(a, tableAnswer{t, g.args})
+}
+
+func (g TabledCall) String() string {
+	var vars []string
+	for _, v := range g.rel.vars {
+		vars = append(vars, string(v))
+	}
+	return fmt.Sprintf("((tabled (%s) %s) %v)", strings.Join(vars, " "), g.rel.body.ToString(), g.args)
+}
+
+// Record the answer of the producer in the table, fail for the answers
+// that were already recorded (master in Byrd, 2009)
+type tableAnswer struct {
+	table *table
+	args  any
+}
+
+func (g tableAnswer) Query(s *Stream) (Answers, error) {
+	if g.table.add(s.variant(g.args)) {
+		return s, nil
+	}
+	return nil, nil
+}
+
+func (g tableAnswer) String() string {
+	return fmt.Sprintf("(table-answer %v)", g.args)
+}
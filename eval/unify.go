@@ -2,6 +2,9 @@ package eval
 
 import (
 	"fmt"
+	"io"
+	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/twolodzko/kanren/types"
@@ -13,25 +16,55 @@ type KeyVal struct {
 	val any
 }
 
-// The alist holding key-value pairs for the unification results
-// (see Byrd, 2009, p. 25)
+// The substitution holding the unification results, it plays the same role
+// as the alist (see Byrd, 2009, p. 25), but is a persistent map, and the store
+// for the constraints
 type Stream struct {
-	list []KeyVal
+	subst   substitution
+	diseqs  []Prefix
+	typed   []KeyVal
+	absents []KeyVal
+	domains []KeyVal
+	fdcs    []fdConstraint
+	// unsound unification without the occurs check when false
+	occursCheck bool
+	tables      tables
+	budget      *budget
+	// the debugging information is written to it when not nil
+	debug io.Writer
+	// records the new bindings when not nil
+	trail *[]KeyVal
 }
 
 func NewStream() *Stream {
-	return &Stream{make([]KeyVal, 0)}
+	return &Stream{occursCheck: true}
 }
 
 // Unify two values, return status (see Byrd, 2009, p. 29)
 func (s *Stream) unify(u, v any) bool {
-	if Debug {
-		fmt.Printf(" ↪ unify: (== %v %v)\n", types.ToString(u), types.ToString(v))
-		fmt.Printf("   subst: %v\n", s)
+	if s.occursCheck {
+		return s.unifyTerms(u, v, nil)
+	}
+	// without the occurs check the terms can be cyclic, the pairs of terms
+	// that were already reached are assumed to unify (coinduction)
+	return s.unifyTerms(u, v, make(map[[2]any]bool))
+}
+
+func (s *Stream) unifyTerms(u, v any, seen map[[2]any]bool) bool {
+	if s.debug != nil {
+		fmt.Fprintf(s.debug, " ↪ unify: (== %v %v)\n", types.ToString(u), types.ToString(v))
+		fmt.Fprintf(s.debug, "   subst: %v\n", s)
+	}
+	if seen != nil && assumable(u, v) {
+		key := [2]any{u, v}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
 	}
 	u = s.walk(u)
 	v = s.walk(v)
-	if u == v {
+	if types.Eqv(u, v) {
 		return true
 	}
 	if u, ok := u.(types.Variable); ok {
@@ -42,29 +75,66 @@ func (s *Stream) unify(u, v any) bool {
 	}
 	if u, ok := u.(types.Pair); ok {
 		if v, ok := v.(types.Pair); ok {
-			if !s.unify(u.This, v.This) {
+			if !s.unifyTerms(u.This, v.This, seen) {
 				return false
 			}
-			return s.unify(u.Next, v.Next)
+			return s.unifyTerms(u.Next, v.Next, seen)
+		}
+	}
+	if u, ok := u.(*types.Vector); ok {
+		if v, ok := v.(*types.Vector); ok && len(u.Elems) == len(v.Elems) {
+			// the vectors can be cyclic even with the occurs check
+			key := [2]any{u, v}
+			if seen[key] {
+				return true
+			}
+			if seen == nil {
+				seen = make(map[[2]any]bool)
+			}
+			seen[key] = true
+			for i := range u.Elems {
+				if !s.unifyTerms(u.Elems[i], v.Elems[i], seen) {
+					return false
+				}
+			}
+			return true
 		}
 	}
 	return false
 }
 
+// Any cycle in the terms goes through a variable or a vector, the pairs
+// of vectors are remembered when they are reached, so it is enough
+// to remember the pairs of terms where one of them is a variable
+func assumable(u, v any) bool {
+	_, uvar := u.(types.Variable)
+	_, vvar := v.(types.Variable)
+	if !uvar && !vvar {
+		return false
+	}
+	return reflect.ValueOf(u).Comparable() && reflect.ValueOf(v).Comparable()
+}
+
 func (s Stream) reify(v any) any {
 	v = s.deepWalk(v)
 	fresh := NewStream()
+	fresh.debug = s.debug
 	fresh.reifyStream(v)
-	return fresh.deepWalk(v)
+	answer := fresh.deepWalk(v)
+	constraints := s.reifyConstraints(fresh)
+	if len(constraints) == 0 {
+		return answer
+	}
+	return types.List(append([]any{answer}, constraints...)...)
 }
 
 func (s *Stream) reifyStream(v any) bool {
 	switch v := s.walk(v).(type) {
 	case types.Variable:
 		free := types.Free(s.len())
-		if Debug {
-			fmt.Printf(" ↪ reify: %v = %v\n", types.ToString(v), types.ToString(free))
-			fmt.Printf("   subst: %v\n", s)
+		if s.debug != nil {
+			fmt.Fprintf(s.debug, " ↪ reify: %v = %v\n", types.ToString(v), types.ToString(free))
+			fmt.Fprintf(s.debug, "   subst: %v\n", s)
 		}
 		return s.extend(v, free)
 	case types.Pair:
@@ -80,6 +150,14 @@ func (s *Stream) reifyStream(v any) bool {
 				return s.reifyStream(head)
 			}
 		}
+	case *types.Vector:
+		for _, e := range v.Elems {
+			if !s.reifyStream(e) {
+				return false
+			}
+		}
+	case types.Labelled:
+		return s.reifyStream(v.Value)
 	}
 	return true
 }
@@ -100,67 +178,228 @@ func (s Stream) walk(v any) any {
 	return v
 }
 
+// Recursively get the value for the key and all its elements, the cycles
+// that are possible without the occurs check are replaced with labels
 func (s Stream) deepWalk(v any) any {
-	v = s.walk(v)
-	if v, ok := v.(types.Pair); ok {
-		v := v.Map(func(x any) any {
-			return s.deepWalk(x)
-		})
-		return types.Cons(v...)
+	w := cycleWalker{stream: s}
+	return w.walk(v)
+}
+
+type cycleWalker struct {
+	stream Stream
+	path   []*walkFrame
+	labels int
+}
+
+// The variables leading to the pair or the vector that is currently walked,
+// and the label that was assigned to it if it was referenced by its elements
+type walkFrame struct {
+	vars  []types.Variable
+	vec   *types.Vector
+	label *int
+}
+
+func (w *cycleWalker) walk(v any) any {
+	frame := &walkFrame{}
+	for {
+		x, ok := v.(types.Variable)
+		if !ok {
+			break
+		}
+		if b, ok := w.backref(func(f *walkFrame) bool { return slices.Contains(f.vars, x) }); ok {
+			return b
+		}
+		val, ok := w.stream.get(x)
+		if !ok || val == x {
+			return x
+		}
+		frame.vars = append(frame.vars, x)
+		v = val
 	}
-	return v
+	switch v := v.(type) {
+	case types.Pair:
+		return w.walkElems(frame, func() any { return types.Cons(v.Map(w.walk)...) })
+	case *types.Vector:
+		if b, ok := w.backref(func(f *walkFrame) bool { return f.vec == v }); ok {
+			return b
+		}
+		frame.vec = v
+		return w.walkElems(frame, func() any { return v.Map(w.walk) })
+	case types.Labelled:
+		return types.Labelled{Label: v.Label, Value: w.walk(v.Value)}
+	default:
+		return v
+	}
+}
+
+// Walk the elements of the pair or the vector, that are
+// labelled if they refer to it
+func (w *cycleWalker) walkElems(frame *walkFrame, walk func() any) any {
+	w.path = append(w.path, frame)
+	out := walk()
+	w.path = w.path[:len(w.path)-1]
+	if frame.label != nil {
+		return types.Labelled{Label: *frame.label, Value: out}
+	}
+	return out
+}
+
+// Reference to the enclosing pair or vector if the value leads to it
+func (w *cycleWalker) backref(leadsTo func(*walkFrame) bool) (types.Backref, bool) {
+	for _, frame := range w.path {
+		if !leadsTo(frame) {
+			continue
+		}
+		if frame.label == nil {
+			label := w.labels
+			w.labels++
+			frame.label = &label
+		}
+		return types.Backref(*frame.label), true
+	}
+	return 0, false
 }
 
 // Check for circular references between keys and values (see Byrd, 2009, p. 28)
 func (s Stream) occurs(u any, v any) bool {
-	v = s.walk(v)
-	switch val := v.(type) {
-	case types.Variable:
-		return u == val
-	case types.Pair:
-		return val.Any(func(v any) bool {
-			return s.occurs(u, v)
-		})
+	return s.occursIn(u, v, nil)
+}
+
+// The path holds the bound variables leading to the value, reaching one of
+// them again means that the value is cyclic and it was already checked
+func (s Stream) occursIn(u any, v any, path *varPath) bool {
+	for {
+		switch val := v.(type) {
+		case types.Variable:
+			if u == val {
+				return true
+			}
+			if path.contains(val) {
+				return false
+			}
+			next, ok := s.get(val)
+			if !ok || next == val {
+				return false
+			}
+			path = s.extendPath(path, val)
+			v = next
+		case types.Pair:
+			if s.occursIn(u, val.This, path) {
+				return true
+			}
+			v = val.Next
+		case *types.Vector:
+			if path.contains(val) {
+				return false
+			}
+			path = &varPath{val, path}
+			for _, e := range val.Elems {
+				if s.occursIn(u, e, path) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+}
+
+// The bound variables and the vectors that were walked to reach the value
+type varPath struct {
+	key  any
+	prev *varPath
+}
+
+// The substitution can be cyclic only without the occurs check,
+// otherwise there is no need to track the path
+func (s Stream) extendPath(path *varPath, x types.Variable) *varPath {
+	if s.occursCheck {
+		return path
+	}
+	return &varPath{x, path}
+}
+
+func (p *varPath) contains(x any) bool {
+	for ; p != nil; p = p.prev {
+		if p.key == x {
+			return true
+		}
 	}
 	return false
 }
 
 func (s *Stream) extend(u types.Variable, v any) bool {
-	// occurs check
-	// if s.occurs(u, v) {
-	// 	return false
-	// }
-	s.list = append(s.list, KeyVal{u, v})
-	return true
+	if s.occursCheck && s.occurs(u, v) {
+		return false
+	}
+	s.subst = s.subst.set(u, v)
+	if s.trail != nil {
+		*s.trail = append(*s.trail, KeyVal{u, v})
+	}
+	return s.checkAttributes(u, v)
 }
 
 func (s Stream) get(v types.Variable) (any, bool) {
-	for i := s.len() - 1; i >= 0; i-- {
-		if s.list[i].key == v {
-			return s.list[i].val, true
-		}
-	}
-	return nil, false
+	return s.subst.get(v)
 }
 
 func (s *Stream) birthRecord(key types.Variable) {
-	s.list = append(s.list, KeyVal{key, key})
+	s.subst = s.subst.set(key, key)
 }
 
-func (s *Stream) keep(size int) {
-	s.list = s.list[:size]
+// Create a copy of the stream that can be extended without affecting the original
+func (s Stream) fork() *Stream {
+	// the substitution is persistent, for the constraints the capacity
+	// is limited, so append always copies the shared elements
+	return &Stream{
+		subst:       s.subst,
+		diseqs:      limited(s.diseqs),
+		typed:       limited(s.typed),
+		absents:     limited(s.absents),
+		domains:     limited(s.domains),
+		fdcs:        limited(s.fdcs),
+		occursCheck: s.occursCheck,
+		tables:      s.tables,
+		budget:      s.budget,
+		debug:       s.debug,
+	}
+}
+
+func limited[T any](s []T) []T {
+	return s[:len(s):len(s)]
 }
 
 func (s Stream) len() int {
-	return len(s.list)
+	return s.subst.len()
 }
 
 func (s Stream) String() string {
 	var acc []string
-	for _, kv := range s.list {
+	for _, kv := range s.subst.entries() {
 		acc = append(acc, kv.String())
 	}
-	return fmt.Sprintf("[%s]", strings.Join(acc, " "))
+	out := fmt.Sprintf("[%s]", strings.Join(acc, " "))
+	if len(s.diseqs) > 0 {
+		var diseqs []string
+		for _, p := range s.diseqs {
+			diseqs = append(diseqs, p.String())
+		}
+		out += fmt.Sprintf(" =/= [%s]", strings.Join(diseqs, " "))
+	}
+	if len(s.typed) > 0 {
+		out += fmt.Sprintf(" types %v", s.typed)
+	}
+	if len(s.absents) > 0 {
+		out += fmt.Sprintf(" absento %v", s.absents)
+	}
+	if len(s.domains) > 0 {
+		out += fmt.Sprintf(" domains %v", s.domains)
+	}
+	if len(s.fdcs) > 0 {
+		out += fmt.Sprintf(" fd %v", s.fdcs)
+	}
+	return out
 }
 
 func (k KeyVal) String() string {
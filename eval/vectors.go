@@ -0,0 +1,312 @@
+package eval
+
+import (
+	"unicode/utf8"
+
+	"github.com/twolodzko/kanren/types"
+)
+
+// The values passed to the goals, where the vectors are the constant copies,
+// since the vectors in the substitution cannot be changed
+func freezeAll(vals []any) []any {
+	acc := make([]any, len(vals))
+	for i, v := range vals {
+		acc[i] = types.Freeze(v)
+	}
+	return acc
+}
+
+func toVector(val any) (*types.Vector, error) {
+	v, ok := val.(*types.Vector)
+	if !ok {
+		return nil, WrongArg{val}
+	}
+	return v, nil
+}
+
+// Create the vector of the arguments
+//
+//	(vector obj ...)
+func vector(args []any) (any, error) {
+	return types.NewVector(append([]any(nil), args...)...), nil
+}
+
+// The vectors and the bytevectors longer than this are not created,
+// as they could exhaust the memory
+const maxSize = 1 << 24
+
+// Create the vector of the length k, filled with the value or with ()
+//
+//	(make-vector k)
+//	(make-vector k fill)
+func makeVector(args []any) (any, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, ArityError
+	}
+	k, ok := args[0].(int)
+	if !ok || k < 0 {
+		return nil, WrongArg{args[0]}
+	}
+	if k > maxSize {
+		return nil, SizeTooLarge
+	}
+	var fill any
+	if len(args) == 2 {
+		fill = args[1]
+	}
+	elems := make([]any, k)
+	for i := range elems {
+		elems[i] = fill
+	}
+	return types.NewVector(elems...), nil
+}
+
+// The number of the elements of the vector
+//
+//	(vector-length v)
+func vectorLength(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	v, err := toVector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return len(v.Elems), nil
+}
+
+// The k-th element of the vector
+//
+//	(vector-ref v k)
+func vectorRef(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, ArityError
+	}
+	v, err := toVector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	k, err := toIndex(args[1], 0, len(v.Elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	return v.Elems[k], nil
+}
+
+// Replace the k-th element of the vector, it returns the vector,
+// the constant vectors, like the literals, cannot be changed
+//
+//	(vector-set! v k obj)
+func vectorSet(args []any) (any, error) {
+	if len(args) != 3 {
+		return nil, ArityError
+	}
+	v, err := toVector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if v.Const {
+		return nil, ConstantError{v}
+	}
+	k, err := toIndex(args[1], 0, len(v.Elems)-1)
+	if err != nil {
+		return nil, err
+	}
+	v.Elems[k] = args[2]
+	return v, nil
+}
+
+// The list of the elements of the vector
+//
+//	(vector->list v)
+func vectorToList(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	v, err := toVector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.List(v.Elems...), nil
+}
+
+// The vector of the elements of the list
+//
+//	(list->vector l)
+func listToVector(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	var acc []any
+	err := forEachElem(args[0], func(val any) error {
+		acc = append(acc, val)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.NewVector(acc...), nil
+}
+
+func toBytevector(val any) (*types.Bytevector, error) {
+	b, ok := val.(*types.Bytevector)
+	if !ok {
+		return nil, WrongArg{val}
+	}
+	return b, nil
+}
+
+func toByte(val any) (byte, error) {
+	n, err := toIndex(val, 0, 255)
+	return byte(n), err
+}
+
+// Create the bytevector of the arguments
+//
+//	(bytevector byte ...)
+func bytevector(args []any) (any, error) {
+	var acc []byte
+	for _, arg := range args {
+		b, err := toByte(arg)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, b)
+	}
+	return types.NewBytevector(acc...), nil
+}
+
+// Create the bytevector of the length k, filled with the byte or with zeros
+//
+//	(make-bytevector k)
+//	(make-bytevector k byte)
+func makeBytevector(args []any) (any, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, ArityError
+	}
+	k, ok := args[0].(int)
+	if !ok || k < 0 {
+		return nil, WrongArg{args[0]}
+	}
+	if k > maxSize {
+		return nil, SizeTooLarge
+	}
+	var fill byte
+	if len(args) == 2 {
+		var err error
+		fill, err = toByte(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	acc := make([]byte, k)
+	for i := range acc {
+		acc[i] = fill
+	}
+	return types.NewBytevector(acc...), nil
+}
+
+// The number of the bytes of the bytevector
+//
+//	(bytevector-length b)
+func bytevectorLength(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	b, err := toBytevector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return len(b.Bytes), nil
+}
+
+// The k-th byte of the bytevector
+//
+//	(bytevector-u8-ref b k)
+func bytevectorRef(args []any) (any, error) {
+	if len(args) != 2 {
+		return nil, ArityError
+	}
+	b, err := toBytevector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	k, err := toIndex(args[1], 0, len(b.Bytes)-1)
+	if err != nil {
+		return nil, err
+	}
+	return int(b.Bytes[k]), nil
+}
+
+// Replace the k-th byte of the bytevector, it returns the bytevector,
+// the constant bytevectors, like the literals, cannot be changed
+//
+//	(bytevector-u8-set! b k byte)
+func bytevectorSet(args []any) (any, error) {
+	if len(args) != 3 {
+		return nil, ArityError
+	}
+	b, err := toBytevector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if b.Const {
+		return nil, ConstantError{b}
+	}
+	k, err := toIndex(args[1], 0, len(b.Bytes)-1)
+	if err != nil {
+		return nil, err
+	}
+	x, err := toByte(args[2])
+	if err != nil {
+		return nil, err
+	}
+	b.Bytes[k] = x
+	return b, nil
+}
+
+// Join the bytevectors
+//
+//	(bytevector-append b ...)
+func bytevectorAppend(args []any) (any, error) {
+	var acc []byte
+	for _, arg := range args {
+		b, err := toBytevector(arg)
+		if err != nil {
+			return nil, err
+		}
+		acc = append(acc, b.Bytes...)
+	}
+	return types.NewBytevector(acc...), nil
+}
+
+// The string decoded from the UTF-8 bytes
+//
+//	(utf8->string b)
+func utf8ToString(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	b, err := toBytevector(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b.Bytes) {
+		return nil, WrongArg{b}
+	}
+	return string(b.Bytes), nil
+}
+
+// The UTF-8 bytes of the string
+//
+//	(string->utf8 s)
+func stringToUtf8(args []any) (any, error) {
+	if len(args) != 1 {
+		return nil, ArityError
+	}
+	s, err := toString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBytevector([]byte(s)...), nil
+}
@@ -0,0 +1,93 @@
+;; Finite-domain constraints over integers
+
+(load "examples/stdlib.scm")
+
+;; Create a list of n fresh variables
+(define fresh-listo
+   (lambda (n out)
+      (cond
+         ((= n 0) (== '() out))
+         (else
+            (fresh (a d)
+               (== (cons a d) out)
+               (fresh-listo (- n 1) d))))))
+
+(define infd-listo
+   (lambda (l dom)
+      (cond
+         ((null? l) succeed)
+         (else
+            (fresh ()
+               (infd (car l) dom)
+               (infd-listo (cdr l) dom))))))
+
+;; ============= N-queens =============
+
+;; The queen q does not attack any of the queens in the following columns,
+;; where d is the distance to the next column
+(define safeo
+   (lambda (q others d n)
+      (cond
+         ((null? others) succeed)
+         (else
+            (fresh (a b)
+               (infd a b (range 1 (* 2 n)))
+               (+fd q d a)
+               (=/=fd a (car others))
+               (+fd (car others) d b)
+               (=/=fd b q)
+               (safeo q (cdr others) (+ d 1) n))))))
+
+(define all-safeo
+   (lambda (qs n)
+      (cond
+         ((null? qs) succeed)
+         (else
+            (fresh ()
+               (safeo (car qs) (cdr qs) 1 n)
+               (all-safeo (cdr qs) n))))))
+
+;; The i-th element of qs is the row of the queen in the i-th column
+(define queenso
+   (lambda (n qs)
+      (fresh ()
+         (fresh-listo n qs)
+         (project (qs)
+            (infd-listo qs (range 1 n))
+            (distinctfd qs)
+            (all-safeo qs n)))))
+
+(test-check "4 queens"
+   (run* (q) (queenso 4 q))
+   '((2 4 1 3) (3 1 4 2)))
+
+(test-check "6 queens"
+   (run* (q) (queenso 6 q))
+   '((2 4 6 1 3 5) (3 6 2 5 1 4) (4 1 5 2 6 3) (5 3 1 6 4 2)))
+
+(test-check "8 queens"
+   (length (run* (q) (queenso 8 q)))
+   92)
+
+;; ============= scheduling =============
+
+;; Tasks a, b, c, and d take 2, 3, 1, and 2 hours. Task b needs to start
+;; after a finishes, c after b, and d after a. Nothing can start before 9,
+;; everything needs to be done by 15, tasks c and d cannot run together.
+(test-check "schedule"
+   (run* (a b c d)
+      (fresh (ae be ce de)
+         (infd a b c d ae be ce de (range 9 15))
+         (+fd a 2 ae)
+         (+fd b 3 be)
+         (+fd c 1 ce)
+         (+fd d 2 de)
+         (<=fd ae b)
+         (<=fd be c)
+         (<=fd ae d)
+         (conde
+            ((<=fd ce d))
+            ((<=fd de c)))
+         (<=fd ce 15)
+         (<=fd de 15)))
+   '((9 11 14 11) (9 11 14 12)))
@@ -0,0 +1,128 @@
+;; Macros defined with syntax-rules
+
+(load "examples/mkprelude.scm")
+
+;; The temporary variable introduced by the macro
+;; does not capture the variable with the same name
+(define-syntax swap!
+   (syntax-rules ()
+      ((_ a b)
+         (let ((tmp a))
+            (set! a b)
+            (set! b tmp)))))
+
+(define tmp 1)
+(define other 2)
+(swap! tmp other)
+
+(test-check "swap!"
+   (list tmp other)
+   '(2 1))
+
+(define-syntax my-or
+   (syntax-rules ()
+      ((_) #f)
+      ((_ e) e)
+      ((_ e r ...)
+         (let ((t e))
+            (cond
+               (t t)
+               (else (my-or r ...)))))))
+
+(define t 5)
+
+(test-check "my-or"
+   (my-or #f t)
+   5)
+
+;; The names used by the template refer to the definitions
+;; visible where the macro was defined
+(test-check "let-syntax"
+   (let ((x 'outer))
+      (let-syntax ((get-x (syntax-rules () ((_) x))))
+         (let ((x 'inner))
+            (get-x))))
+   'outer)
+
+(define-syntax while
+   (syntax-rules ()
+      ((_ test body ...)
+         (let loop ()
+            (when test
+               body ...
+               (loop))))))
+
+(define i 0)
+(while (< i 1000)
+   (set! i (+ i 1)))
+
+(test-check "while"
+   i
+   1000)
+
+;; The nested ellipses and the patterns following the ellipsis
+(define-syntax flip-all
+   (syntax-rules ()
+      ((_ (a b ...) ...)
+         '((b ... a) ...))))
+
+(test-check "nested ellipses"
+   (flip-all (1 2 3) (4 5))
+   '((2 3 1) (5 4)))
+
+(define-syntax last-of
+   (syntax-rules ()
+      ((_ x ... z) 'z)))
+
+(test-check "last-of"
+   (last-of 1 2 3)
+   3)
+
+;; The kanren operators can be defined as macros
+
+(define-syntax define-relation
+   (syntax-rules ()
+      ((_ (name arg ...) g ...)
+         (define name
+            (lambda (arg ...)
+               (fresh () g ...))))))
+
+(define-relation (firsto l a)
+   (fresh (d)
+      (== (cons a d) l)))
+
+(test-check "define-relation"
+   (run* (q) (firsto '(1 2 3) q))
+   '(1))
+
+;; conde where the last branch can be marked with else
+(define-syntax conde*
+   (syntax-rules (else)
+      ((_ (else g ...))
+         (fresh () g ...))
+      ((_ (g ...) c ...)
+         (conde
+            ((fresh () g ...))
+            ((conde* c ...))))))
+
+(define-relation (smallo x)
+   (conde*
+      ((== x 1))
+      ((== x 2))
+      (else (== x 3))))
+
+(test-check "conde*"
+   (run* (q) (smallo q))
+   '(1 2 3))
+
+;; The fresh variable introduced by the macro
+;; is different from the one passed as an argument
+(define-syntax pairo
+   (syntax-rules ()
+      ((_ p)
+         (fresh (a d)
+            (== (cons a d) p)))))
+
+(test-check "hygienic fresh"
+   (run* (a) (pairo a))
+   '((_.0 . _.1)))
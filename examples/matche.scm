@@ -0,0 +1,64 @@
+;; Relations defined with defrel, matche, and lambdae
+
+(load "examples/stdlib.scm")
+
+;; The pattern variable named as the argument of the relation refers to it
+(defrel (appendo l s out)
+   (matche (l out)
+      ((() ,s))
+      (((,a . ,d) (,a . ,res))
+         (appendo d s res))))
+
+(test-check "appendo"
+   (run* (x y) (appendo x y '(1 2 3)))
+   '((() (1 2 3)) ((1) (2 3)) ((1 2) (3)) ((1 2 3) ())))
+
+(define membero
+   (lambdae (x l)
+      ((_ (,x . _)))
+      ((_ (_ . ,d))
+         (membero x d))))
+
+(test-check "membero"
+   (run* (q) (membero q '(a b c)))
+   '(a b c))
+
+;; The patterns can be quasiquoted explicitly
+(defrel (lasto l x)
+   (matche l
+      (`(,x))
+      (`(_ . ,d) (lasto d x))))
+
+(test-check "lasto"
+   (run* (q) (lasto '(1 2 3) q))
+   '(3))
+
+;; The symbols in the patterns are the constants,
+;; and the variables repeated in the pattern are the same
+(defrel (tago x tag)
+   (matche x
+      ((pair ,a ,a) (== tag 'same))
+      ((pair _ _) (== tag 'any))))
+
+(test-check "tago"
+   (run* (q) (tago '(pair 1 1) q))
+   '(same any))
+
+(test-check "tago inverse"
+   (run* (q) (tago q 'same))
+   '((pair _.0 _.0)))
+
+;; The calls of the relations defined with defrel are delayed,
+;; so the recursive relation can be passed to a Scheme function
+(define disj
+   (lambda (g1 g2)
+      (conde (g1) (g2))))
+
+(defrel (alwayso x)
+   (disj
+      (== x 'ok)
+      (alwayso x)))
+
+(test-check "alwayso"
+   (run 3 (q) (alwayso q))
+   '(ok ok ok))
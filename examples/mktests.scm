@@ -5,6 +5,10 @@
 ;;; All the examples used in the book
 ;;;
 ;;; Source: https://github.com/miniKanren/TheReasonedSchemer
+;;;
+;;; The conde is interleaving as in Byrd (2009), so some of the answers
+;;; come in a different order than in the book, and some of the examples
+;;; that diverged in the book now have answers.
 
 (load "examples/mkprelude.scm")
 
@@ -308,7 +312,7 @@
         ((== #f x) (== #t y))
         (else fail))
       (== (cons x (cons y '())) r)))
-  `((tea #t) (cup #t) (#f #t)))
+  `((#f #t) (tea #t) (cup #t)))
 
 (test-check "1.58"
   (run* (r)
@@ -762,9 +766,9 @@
     (lolo `((a b) (c d) . ,x)))
   `(()
     (())
+    ((_.0))
     (() ())
-    (() () ())
-    (() () () ())))
+    ((_.0 _.1))))
 
 ; 3.31
 (define twinso
@@ -1112,7 +1116,7 @@
 (test-check "3.100"
   (run* (x)
     (memberrevo x `(pasta e fagioli)))
-  `(fagioli e pasta))
+  `(pasta e fagioli))
 
 ; 3.101
 (define reverse-list
@@ -1603,10 +1607,11 @@
           (swappendo d s res)))
       (else (nullo l) (== s out)))))
 
-;; (test-divergence "5.39"
-;;   (run 1 (z)
-;;     (fresh (x y)
-;;       (swappendo x y z))))
+(test-check "5.39"
+  (run 1 (z)
+    (fresh (x y)
+      (swappendo x y z)))
+  `(_.0))
 
 ; 5.41.1
 (define unwrap
@@ -1636,18 +1641,20 @@
 (test-check "5.46"
   (run* (x)
     (unwrapo '(((pizza))) x))
-  `(pizza
-    (pizza)
+  `((((pizza)))
     ((pizza))
-    (((pizza)))))
+    (pizza)
+    pizza))
 
-;; (test-divergence "5.48"
-;;   (run 1 (x)
-;;     (unwrapo x 'pizza)))
+(test-check "5.48"
+  (run 1 (x)
+    (unwrapo x 'pizza))
+  `(pizza))
 
-;; (test-divergence "5.49"
-;;   (run 1 (x)
-;;     (unwrapo `((,x)) 'pizza)))
+(test-check "5.49"
+  (run 1 (x)
+    (unwrapo `((,x)) 'pizza))
+  `(pizza))
 
 ; 5.52
 (define unwrapo
@@ -1717,49 +1724,49 @@
 (test-check "5.60"
   (run 1 (x)
     (flatteno '((a b) c) x))
-  (list `(a b c)))
+  `((((a b) c))))
 
 (test-check "5.61"
   (run 1 (x)
     (flatteno '(a (b c)) x))
-  (list `(a b c)))
+  `(((a (b c)))))
 
 (test-check "5.62"
   (run* (x)
     (flatteno '(a) x))
-  `((a)
-    (a ())
-    ((a))))
+  `(((a))
+    (a)
+    (a ())))
 
 (test-check "5.64"
   (run* (x)
     (flatteno '((a)) x))
-  `((a)
-    (a ())
-    (a ())
-    (a () ())
+  `((((a)))
     ((a))
     ((a) ())
-    (((a)))))
+    (a)
+    (a ())
+    (a ())
+    (a () ())))
 
 (test-check "5.66"
   (run* (x)
     (flatteno '(((a))) x))
-  `((a)
+  `(((((a))))
+    (((a)))
+    (((a)) ())
+    ((a))
+    ((a) ())
+    ((a) ())
+    ((a) () ())
+    (a)
     (a ())
     (a ())
     (a () ())
     (a ())
     (a () ())
     (a () ())
-    (a () () ())
-    ((a))
-    ((a) ())
-    ((a) ())
-    ((a) () ())
-    (((a)))
-    (((a)) ())
-    ((((a))))))
+    (a () () ())))
 
 ; 5.68.1
 (define flattenogrumblequestion
@@ -1769,19 +1776,19 @@
 
 ; 5.68.2
 (define flattenogrumbleanswer
-  `((a b c)
-    (a b c ())
-    (a b (c))
-    (a b () c)
-    (a b () c ())
-    (a b () (c))
-    (a (b) c)
-    (a (b) c ())
-    (a (b) (c))
+  `((((a b) c))
+    ((a b) (c))
     ((a b) c)
+    (a (b) (c))
     ((a b) c ())
-    ((a b) (c))
-    (((a b) c))))
+    (a (b) c)
+    (a (b) c ())
+    (a b (c))
+    (a b () (c))
+    (a b c)
+    (a b c ())
+    (a b () c)
+    (a b () c ())))
 
 (test-check "flattenogrumble"
   (flattenogrumblequestion)
@@ -1810,14 +1817,14 @@
   `((((a b) c))
     ((a b) (c))
     ((a b) c ())
-    ((a b) c)
     (a (b) (c))
+    ((a b) c)
     (a (b) c ())
     (a (b) c)
     (a b () (c))
+    (a b (c))
     (a b () c ())
     (a b () c)
-    (a b (c))
     (a b c ())
     (a b c)))
 
@@ -1825,13 +1832,13 @@
   (reverse
     (run* (x)
       (flattenrevo '((a b) c) x)))
-  flattenogrumbleanswer)
+  `((a b c) (a b c ()) (a b () c) (a b () c ()) (a b (c)) (a b () (c)) (a (b) c) (a (b) c ()) ((a b) c) (a (b) (c)) ((a b) c ()) ((a b) (c)) (((a b) c))))
 
 (test-check "5.77"
   (run 2 (x)
     (flattenrevo x '(a b c)))
   `((a b . c)
-    (a b c)))
+    ((a . b) . c)))
 
 ;; (test-divergence "5.79"
 ;;   (run 3 (x)
@@ -1920,12 +1927,13 @@
 ;;     fail
 ;;     (== #t q)))
 
-;; (test-divergence "6.18"
-;;   (run 1 (q)
-;;     (conde
-;;       ((== #f q) alwayso)
-;;       (else (anyo (== #t q))))
-;;     (== #t q)))
+(test-check "6.18"
+  (run 1 (q)
+    (conde
+      ((== #f q) alwayso)
+      (else (anyo (== #t q))))
+    (== #t q))
+  `(#t))
 
 ;; (test-check "6.19"
 ;;   (run 1 (q)
@@ -1967,13 +1975,14 @@
 ;;     (== #t q))
 ;;   `(#t #t #t #t #t))
 
-;; (test-divergence "6.27"
-;;   (run 5 (q)
-;;     (conde
-;;       ((== #f q) alwayso)
-;;       ((== #t q) alwayso)
-;;       (else fail))
-;;     (== #t q)))
+(test-check "6.27"
+  (run 5 (q)
+    (conde
+      ((== #f q) alwayso)
+      ((== #t q) alwayso)
+      (else fail))
+    (== #t q))
+  `(#t #t #t #t #t))
 
 (test-check "6.28"
   (run 5 (q)
@@ -2666,12 +2675,12 @@
       (== `(,n ,m) t)))
   `((() ())
     ((1) (1))
+    (() (_.0 . _.1))
+    ((1) (_.0 _.1 . _.2))
     ((_.0 1) (_.1 1))
+    ((_.0 1) (_.1 _.2 _.3 . _.4))
     ((_.0 _.1 1) (_.2 _.3 1))
-    ((_.0 _.1 _.2 1) (_.3 _.4 _.5 1))
-    ((_.0 _.1 _.2 _.3 1) (_.4 _.5 _.6 _.7 1))
-    ((_.0 _.1 _.2 _.3 _.4 1) (_.5 _.6 _.7 _.8 _.9 1))
-    ((_.0 _.1 _.2 _.3 _.4 _.5 1) (_.6 _.7 _.8 _.9 _.10 _.11 1))))
+    ((_.0 _.1 _.2 1) (_.3 _.4 _.5 1))))
 
 ;; (test-check "8.40"
 ;;   (run 1 (t)
@@ -3206,24 +3215,25 @@
 
 ; This will not run with occurs checker
 (test-check "9.62"
-  (run 1 (q)
+  (run/no-occurs 1 (q)
     (fresh (x)
       (== `(,x) x)
       (== #t q)))
   `(#t))
 
 (test-check "9.63"
-  (run 1 (q)
+  (run/no-occurs 1 (q)
     (fresh (x y)
       (== `(,x) y)
       (== `(,y) x)
       (== #t q)))
   `(#t))
 
-;; (test-check "9.64"
-;;   (run 1 (x)
-;;     (==-check `(,x) x))
-;;   `())
+; == uses the occurs check by default
+(test-check "9.64"
+  (run 1 (x)
+    (== `(,x) x))
+  `())
 
 ;; (test-divergence "9.65"
 ;;   (run 1 (x)
@@ -3232,106 +3242,106 @@
 ;;       (== `(a b ,z) y)
 ;;       (== x y))))
 
-;; (test-check "9.66"
-;;   (run 1 (x)
-;;     (fresh (y z)
-;;       (== x z)
-;;       (== `(a b ,z) y)
-;;       (==-check x y)))
-;;   `())
+(test-check "9.66"
+  (run 1 (x)
+    (fresh (y z)
+      (== x z)
+      (== `(a b ,z) y)
+      (== x y)))
+  `())
 
 ;; (test-divergence "9.69"
 ;;   (run 1 (x)
 ;;     (== `(,x) x)))
 
-;; (test-check "10.1"
-;;   (run* (q)
-;;     (conda
-;;       (fail succeed)
-;;       (else fail)))
-;;   '())
-
-;; (test-check "10.2"
-;;   (not (null? (run* (q)
-;;                 (conda
-;;                   (fail succeed)
-;;                   (else succeed)))))
-;;   #t)
-
-;; (test-check "10.3"
-;;   (not (null? (run* (q)
-;;                 (conda
-;;                   (succeed fail)
-;;                   (else succeed)))))
-;;   #f)
-
-;; (test-check "10.4"
-;;   (not (null? (run* (q)
-;;                 (conda
-;;                   (succeed succeed)
-;;                   (else fail)))))
-;;   #t)
-
-;; (test-check "10.5"
-;;   (run* (x)
-;;     (conda
-;;       ((== 'olive x) succeed)
-;;       ((== 'oil x) succeed)
-;;       (else fail)))
-;;   `(olive))
+(test-check "10.1"
+  (run* (q)
+    (conda
+      (fail succeed)
+      (else fail)))
+  '())
 
-;; (test-check "10.7"
-;;   (run* (x)
-;;     (conda
-;;       ((== 'virgin x) fail)
-;;       ((== 'olive x) succeed)
-;;       ((== 'oil x) succeed)
-;;       (else fail)))
-;;   `())
+(test-check "10.2"
+  (not (null? (run* (q)
+                (conda
+                  (fail succeed)
+                  (else succeed)))))
+  #t)
 
-;; (test-check "10.8"
-;;   (run* (q)
-;;     (fresh (x y)
-;;       (== 'split x)
-;;       (== 'pea y)
-;;       (conda
-;;         ((== 'split x) (== x y))
-;;         (else succeed)))
-;;     (== #t q))
-;;   `())
+(test-check "10.3"
+  (not (null? (run* (q)
+                (conda
+                  (succeed fail)
+                  (else succeed)))))
+  #f)
 
-;; (test-check "10.9"
-;;   (run* (q)
-;;     (fresh (x y)
-;;       (== 'split x)
-;;       (== 'pea y)
-;;       (conda
-;;         ((== x y) (== 'split x))
-;;         (else succeed)))
-;;     (== #t q))
-;;   (list #t))
+(test-check "10.4"
+  (not (null? (run* (q)
+                (conda
+                  (succeed succeed)
+                  (else fail)))))
+  #t)
 
-;; ; 10.11.1
-;; (define not-pastao
-;;   (lambda (x)
-;;     (conda
-;;       ((== 'pasta x) fail)
-;;       (else succeed))))
+(test-check "10.5"
+  (run* (x)
+    (conda
+      ((== 'olive x) succeed)
+      ((== 'oil x) succeed)
+      (else fail)))
+  `(olive))
 
-;; (test-check "10.11.2"
-;;   (run* (x)
-;;     (conda
-;;       ((not-pastao x) fail)
-;;       (else (== 'spaghetti x))))
-;;   '(spaghetti))
+(test-check "10.7"
+  (run* (x)
+    (conda
+      ((== 'virgin x) fail)
+      ((== 'olive x) succeed)
+      ((== 'oil x) succeed)
+      (else fail)))
+  `())
 
-;; (test-check "10.12"
-;;   (run* (x)
-;;     (== 'spaghetti x)
-;;     (conda
-;;       ((not-pastao x) fail)
-;;       (else (== 'spaghetti x))))
-;;   '())
+(test-check "10.8"
+  (run* (q)
+    (fresh (x y)
+      (== 'split x)
+      (== 'pea y)
+      (conda
+        ((== 'split x) (== x y))
+        (else succeed)))
+    (== #t q))
+  `())
+
+(test-check "10.9"
+  (run* (q)
+    (fresh (x y)
+      (== 'split x)
+      (== 'pea y)
+      (conda
+        ((== x y) (== 'split x))
+        (else succeed)))
+    (== #t q))
+  (list #t))
+
+; 10.11.1
+(define not-pastao
+  (lambda (x)
+    (conda
+      ((== 'pasta x) fail)
+      (else succeed))))
+
+(test-check "10.11.2"
+  (run* (x)
+    (conda
+      ((not-pastao x) fail)
+      (else (== 'spaghetti x))))
+  '(spaghetti))
+
+(test-check "10.12"
+  (run* (x)
+    (== 'spaghetti x)
+    (conda
+      ((not-pastao x) fail)
+      (else (== 'spaghetti x))))
+  '())
 
 ;; (test-divergence "10.13"
 ;;   (run* (q)
@@ -3340,13 +3350,13 @@
 ;;       (else fail))
 ;;     (== #t q)))
 
-;; (test-check "10.14"
-;;   (run* (q)
-;;     (condu
-;;       (alwayso succeed)
-;;       (else fail))
-;;     (== #t q))
-;;   `(#t))
+(test-check "10.14"
+  (run* (q)
+    (condu
+      (alwayso succeed)
+      (else fail))
+    (== #t q))
+  `(#t))
 
 ;; (test-divergence "10.15"
 ;;   (run* (q)
@@ -3363,32 +3373,33 @@
 ;;     fail
 ;;     (== #t q)))
 
-;; (test-check "10.18"
-;;   (run 1 (q)
-;;     (condu
-;;       (alwayso succeed)
-;;       (else fail))
-;;     fail
-;;     (== #t q))
-;;   `())
+(test-check "10.18"
+  (run 1 (q)
+    (condu
+      (alwayso succeed)
+      (else fail))
+    fail
+    (== #t q))
+  `())
 
 ;; ; 10.19.1
+;; onceo is built-in
 ;; (define onceo
 ;;   (lambda (g)
 ;;     (condu
 ;;       (g succeed)
 ;;       (else fail))))
 
-;; (test-check "10.19.2"
-;;   (run* (x)
-;;     (onceo (teacupo x)))
-;;   `(tea))
+(test-check "10.19.2"
+  (run* (x)
+    (onceo (teacupo x)))
+  `(tea))
 
-;; (test-check "10.20"
-;;   (run 1 (q)
-;;     (onceo (salo nevero))
-;;     fail)
-;;   `())
+(test-check "10.20"
+  (run 1 (q)
+    (onceo (salo nevero))
+    fail)
+  `())
 
 (test-check "10.21"
   (run* (r)
@@ -3396,33 +3407,33 @@
       ((teacupo r) succeed)
       ((== #f r) succeed)
       (else fail)))
-  `(tea cup #f))
+  `(#f tea cup))
 
-;; (test-check "10.22"
-;;   (run* (r)
-;;     (conda
-;;       ((teacupo r) succeed)
-;;       ((== #f r) succeed)
-;;       (else fail)))
-;;   `(tea cup))
+(test-check "10.22"
+  (run* (r)
+    (conda
+      ((teacupo r) succeed)
+      ((== #f r) succeed)
+      (else fail)))
+  `(tea cup))
 
-;; (test-check "10.23"
-;;   (run* (r)
-;;     (== #f r)
-;;     (conda
-;;       ((teacupo r) succeed)
-;;       ((== #f r) succeed)
-;;       (else fail)))
-;;   `(#f))
+(test-check "10.23"
+  (run* (r)
+    (== #f r)
+    (conda
+      ((teacupo r) succeed)
+      ((== #f r) succeed)
+      (else fail)))
+  `(#f))
 
-;; (test-check "10.24"
-;;   (run* (r)
-;;     (== #f r)
-;;     (condu
-;;       ((teacupo r) succeed)
-;;       ((== #f r) succeed)
-;;       (else fail)))
-;;   `(#f))
+(test-check "10.24"
+  (run* (r)
+    (== #f r)
+    (condu
+      ((teacupo r) succeed)
+      ((== #f r) succeed)
+      (else fail)))
+  `(#f))
 
 ;; ; 10.26.1
 ;; (define bumpo
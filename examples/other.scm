@@ -56,7 +56,7 @@
                 ((== #f x) (== #t y))
                 (else fail))
             (== (cons x (cons y '())) r)))
-    '((tea #t) (cup #t) (#f #t)))
+    `((#f #t) (tea #t) (cup #t)))
 
 (define appendo
     (lambda (l s out)
@@ -70,10 +70,8 @@
 
 ;; Byrd (2009), p. 19
 (test-check "appendo"
-    (run 6 (q)
-        (fresh (l s)
-            (appendo l s '(a b c d e))
-            (== (list l s) q)))
+    (run 6 (l s)
+        (appendo l s '(a b c d e)))
     '((() (a b c d e)) ((a) (b c d e)) ((a b) (c d e)) ((a b c) (d e)) ((a b c d) (e)) ((a b c d e) ())))
 
 (test-check "triple fresh"
@@ -95,4 +93,4 @@
                     ((== v 1))
                     ((== v 2)))
                 (== v z))))
-    '((a A 1) (a A 2) (a B 1) (a B 2) (b A 1) (b A 2) (b B 1) (b B 2)))
+    `((a A 1) (a A 2) (b A 1) (b A 2) (a B 1) (b B 1) (a B 2) (b B 2)))
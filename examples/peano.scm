@@ -102,18 +102,14 @@
 
 (test-check "pluso"
    (map-peano->int
-      (run* (q)
-         (fresh (n m)
-            (pluso n m (int->peano 6))
-            (== (list n m) q))))
+      (run* (n m)
+         (pluso n m (int->peano 6))))
    '((0 6) (1 5) (2 4) (3 3) (4 2) (5 1) (6 0)))
 
 (test-check "minuso"
    (map-peano->int
-      (run 10 (q)
-         (fresh (n m)
-            (minuso n m (int->peano 6))
-            (== (list n m) q))))
+      (run 10 (n m)
+         (minuso n m (int->peano 6))))
    '((6 0) (7 1) (8 2) (9 3) (10 4) (11 5) (12 6) (13 7) (14 8) (15 9)))
 
 (test-check "eveno"
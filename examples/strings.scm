@@ -0,0 +1,48 @@
+;; Processing the text with the strings and the characters
+
+(load "examples/mkprelude.scm")
+
+(define (capitalize s)
+   (cond
+      ((= (string-length s) 0) s)
+      (else
+         (string-append
+            (list->string (list (char-upcase (string-ref s 0))))
+            (substring s 1)))))
+
+(test-check "capitalize"
+   (capitalize "kanren")
+   "Kanren")
+
+;; Split the string on the whitespace
+(define (words s)
+   (let loop ((chars (string->list s)) (word '()) (acc '()))
+      (define (add-word)
+         (cond
+            ((null? word) acc)
+            (else (cons (list->string (reverse word)) acc))))
+      (cond
+         ((null? chars) (reverse (add-word)))
+         ((char-whitespace? (car chars))
+            (loop (cdr chars) '() (add-word)))
+         (else
+            (loop (cdr chars) (cons (car chars) word) acc)))))
+
+(test-check "words"
+   (words "  the reasoned\tschemer ")
+   '("the" "reasoned" "schemer"))
+
+(test-check "symbols"
+   (map string->symbol (words "run fresh conde"))
+   '(run fresh conde))
+
+;; The characters can be unified like the other values
+(test-check "splits"
+   (map
+      (lambda (split) (map list->string split))
+      (run* (x y) (appendo x y (string->list "abc"))))
+   '(("" "abc") ("a" "bc") ("ab" "c") ("abc" "")))
+
+(test-check "escapes"
+   (string->list "a\tb\x3bb;\n")
+   (list #\a #\tab #\b #\λ #\newline))
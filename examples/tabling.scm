@@ -0,0 +1,64 @@
+;; Tabled relations
+
+(load "examples/stdlib.scm")
+
+;; The graph with cycles
+;;
+;;   a -> b -> c -> a
+;;             c -> d
+(define edgeo
+   (lambda (x y)
+      (conde
+         ((== x 'a) (== y 'b))
+         ((== x 'b) (== y 'c))
+         ((== x 'c) (== y 'a))
+         ((== x 'c) (== y 'd)))))
+
+;; Without tabling, the search for the paths would never end
+(defrel-tabled (patho x y)
+   (conde
+      ((edgeo x y))
+      ((fresh (z)
+         (edgeo x z)
+         (patho z y)))))
+
+(test-check "reachable from a"
+   (run* (q) (patho 'a q))
+   '(b c a d))
+
+(test-check "reachable from d"
+   (run* (q) (patho 'd q))
+   '())
+
+(test-check "reaching d"
+   (run* (q) (patho q 'd))
+   '(c b a))
+
+(test-check "cycles"
+   (run* (q) (patho q q))
+   '(a b c))
+
+(test-check "all the paths"
+   (length (run* (x y) (patho x y)))
+   12)
+
+(test-check "first answers"
+   (run 2 (q) (patho 'a q))
+   '(b c))
+
+;; The left-recursive definition
+(define patho-left
+   (tabled (x y)
+      (conde
+         ((fresh (z)
+            (patho-left x z)
+            (edgeo z y)))
+         ((edgeo x y)))))
+
+(test-check "left recursion"
+   (run* (q) (patho-left 'a q))
+   '(b c a d))
+
+(test-check "onceo"
+   (run* (q) (onceo (patho 'a q)))
+   '(b))
@@ -0,0 +1,72 @@
+;; The vectors as the fixed-size tuples
+
+(load "examples/mkprelude.scm")
+
+;; The moves of the knight on the 3x3 board, the squares are the #(x y) vectors
+(define (knighto from to)
+   (fresh (x y dx dy)
+      (== from (vector x y))
+      (infd x y (range 0 2))
+      (conde
+         ((== (vector dx dy) #(1 2)))
+         ((== (vector dx dy) #(2 1))))
+      (matche to
+         (#(,a ,b)
+            (infd a b (range 0 2))
+            (conde
+               ((+fd x dx a))
+               ((+fd a dx x)))
+            (conde
+               ((+fd y dy b))
+               ((+fd b dy y)))))))
+
+(test-check "knight moves"
+   (run* (q) (knighto #(0 0) q))
+   '(#(1 2) #(2 1)))
+
+(test-check "knight moves backwards"
+   (run* (q) (knighto q #(1 2)))
+   '(#(0 0) #(2 0)))
+
+;; The fields of the records are matched by their positions
+(define (parento p c)
+   (conde
+      ((== `#(,p ,c) #(abe homer)))
+      ((== `#(,p ,c) #(homer bart)))
+      ((== `#(,p ,c) #(homer lisa)))))
+
+(test-check "children"
+   (run* (q) (parento 'homer q))
+   '(bart lisa))
+
+(test-check "grandparents"
+   (run* (q) (fresh (p) (parento q p) (parento p 'lisa)))
+   '(abe))
+
+;; The vectors can be changed in place
+(define (histogram xs size)
+   (let ((counts (make-vector size 0)))
+      (let loop ((xs xs))
+         (cond
+            ((null? xs) counts)
+            (else
+               (begin
+                  (vector-set! counts (car xs) (+ (vector-ref counts (car xs)) 1))
+                  (loop (cdr xs))))))))
+
+(test-check "histogram"
+   (histogram '(0 2 2 1 2) 3)
+   #(1 1 3))
+
+(test-check "lists"
+   (vector->list (list->vector '(a b c)))
+   '(a b c))
+
+;; The bytevectors hold the bytes, e.g. the encoded strings
+(test-check "utf8"
+   (string->utf8 "λx")
+   #u8(206 187 120))
+
+(test-check "bytes"
+   (run* (q) (conde ((== q (bytevector 1 2))) ((== q #u8(3)))) (=/= q #u8(1 2)))
+   '(#u8(3)))
@@ -13,6 +13,12 @@ func TestIntegration(t *testing.T) {
 		"examples/other.scm",
 		"examples/peano.scm",
 		"examples/mktests.scm",
+		"examples/fd.scm",
+		"examples/tabling.scm",
+		"examples/macros.scm",
+		"examples/matche.scm",
+		"examples/strings.scm",
+		"examples/vectors.scm",
 	}
 	for _, file := range files {
 		env := eval.DefaultEnv()
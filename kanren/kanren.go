@@ -0,0 +1,111 @@
+// Package kanren builds and runs the relational queries in Go, without going
+// through the Scheme code. The goals are the same as used by the interpreter.
+//
+//	func appendo(l, s, out any) kanren.Goal {
+//		return kanren.Conde(
+//			kanren.All(kanren.Eq(l, nil), kanren.Eq(s, out)),
+//			kanren.Fresh(3, func(v ...kanren.Var) kanren.Goal {
+//				a, d, res := v[0], v[1], v[2]
+//				return kanren.All(
+//					kanren.Eq(l, types.Pair{This: a, Next: d}),
+//					kanren.Eq(out, types.Pair{This: a, Next: res}),
+//					appendo(d, s, res),
+//				)
+//			}),
+//		)
+//	}
+//
+//	kanren.Run(-1, func(q kanren.Var) kanren.Goal {
+//		return appendo([]int{1, 2}, []int{3}, q)
+//	}) // [(1 2 3)]
+package kanren
+
+import (
+	"github.com/twolodzko/kanren/eval"
+	"github.com/twolodzko/kanren/types"
+)
+
+type (
+	Goal = eval.Goal
+	Var  = types.Variable
+)
+
+var (
+	Succeed = eval.Succeed
+	Fail    = eval.Fail
+)
+
+// Unify the values, the Go values are converted with eval.FromGo
+func Eq(u, v any) Goal {
+	u, v, err := convert(u, v)
+	if err != nil {
+		return errorGoal{err}
+	}
+	return eval.NewUnify(u, v)
+}
+
+// The values can never be unified, the Go values are converted with eval.FromGo
+func Neq(u, v any) Goal {
+	u, v, err := convert(u, v)
+	if err != nil {
+		return errorGoal{err}
+	}
+	return eval.NewDisequality(u, v)
+}
+
+// Query the goal returned by the function for the n fresh variables,
+// the function is called each time the goal is queried
+func Fresh(n int, fn func(vars ...Var) Goal) Goal {
+	return eval.NewFresh(n, fn)
+}
+
+// Interleave the answers of the goals
+func Conde(goals ...Goal) Goal {
+	return eval.NewConde(goals...)
+}
+
+// Conjunction of the goals
+func All(goals ...Goal) Goal {
+	return eval.NewConj(goals...)
+}
+
+// The goal that is created only when it is queried
+func Lazy(fn func() Goal) Goal {
+	return eval.NewLazy(fn)
+}
+
+// Collect at most n answers for the q variable, or all of them when n < 0
+func Run(n int, fn func(q Var) Goal) ([]any, error) {
+	q := types.NewVariable("q")
+	query := eval.NewGoalQuery([]types.Variable{q}, fn(q))
+	var acc []any
+	for n < 0 || len(acc) < n {
+		answer, ok, err := query.Next()
+		if err != nil {
+			return acc, err
+		}
+		if !ok {
+			break
+		}
+		acc = append(acc, answer)
+	}
+	return acc, nil
+}
+
+func convert(u, v any) (any, any, error) {
+	u, err := eval.FromGo(u)
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err = eval.FromGo(v)
+	return u, v, err
+}
+
+// The goal that fails with the error
+type errorGoal struct {
+	err error
+}
+
+func (g errorGoal) Query(*eval.Stream) (eval.Answers, error) {
+	return nil, g.err
+}
@@ -0,0 +1,116 @@
+package kanren
+
+import (
+	"testing"
+
+	"github.com/twolodzko/kanren/eval"
+	"github.com/twolodzko/kanren/types"
+)
+
+func appendo(l, s, out any) Goal {
+	return Conde(
+		All(Eq(l, nil), Eq(s, out)),
+		Fresh(3, func(v ...Var) Goal {
+			a, d, res := v[0], v[1], v[2]
+			return All(
+				Eq(l, types.Pair{This: a, Next: d}),
+				Eq(out, types.Pair{This: a, Next: res}),
+				appendo(d, s, res),
+			)
+		}),
+	)
+}
+
+func TestRun(t *testing.T) {
+	var testCases = []struct {
+		n        int
+		goal     func(q Var) Goal
+		expected string
+	}{
+		{-1, func(q Var) Goal { return Eq(q, 1) }, "(1)"},
+		{-1, func(q Var) Goal { return All(Eq(q, 1), Eq(q, 2)) }, "()"},
+		{-1, func(q Var) Goal { return Conde(Eq(q, "a"), Succeed, Fail) }, "(\"a\" _.0)"},
+		{-1, func(q Var) Goal { return All(Neq(q, 1), Conde(Eq(q, 1), Eq(q, 2))) }, "(2)"},
+		{-1, func(q Var) Goal { return appendo([]int{1, 2}, []int{3}, q) }, "((1 2 3))"},
+		{
+			-1,
+			func(q Var) Goal {
+				return Fresh(2, func(v ...Var) Goal {
+					return All(appendo(v[0], v[1], []int{1, 2}), Eq(q, []any{v[0], v[1]}))
+				})
+			},
+			"((() (1 2)) ((1) (2)) ((1 2) ()))",
+		},
+		{
+			3,
+			func(q Var) Goal {
+				var nato func(n any) Goal
+				nato = func(n any) Goal {
+					return Conde(
+						Eq(n, types.Symbol("z")),
+						Fresh(1, func(m ...Var) Goal {
+							return All(Eq(n, []any{types.Symbol("s"), m[0]}), Lazy(func() Goal { return nato(m[0]) }))
+						}),
+					)
+				}
+				return nato(q)
+			},
+			"(z (s z) (s (s z)))",
+		},
+	}
+	for _, tt := range testCases {
+		result, err := Run(tt.n, tt.goal)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			continue
+		}
+		if got := types.ToString(types.List(result...)); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestRunConverted(t *testing.T) {
+	type Person struct {
+		Name string `kanren:"name"`
+		Age  int    `kanren:"age"`
+	}
+	people := []Person{{"Alice", 42}, {"Bob", 7}}
+
+	result, err := Run(-1, func(q Var) Goal {
+		// q is a member of the list
+		return Fresh(2, func(v ...Var) Goal {
+			return appendo(v[0], types.Pair{This: q, Next: v[1]}, people)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []Person
+	if err := eval.ToGo(types.List(result...), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != people[0] || got[1] != people[1] {
+		t.Errorf("expected %v, got %v", people, got)
+	}
+
+	answers, err := Run(-1, func(q Var) Goal { return Eq(q, 1.5) })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(answers) != 1 || answers[0] != 1.5 {
+		t.Errorf("expected [1.5], got %v", answers)
+	}
+
+	// the answers of the wrong type are reported as errors
+	answers, err = Run(-1, func(q Var) Goal {
+		return Conde(Eq(q, 1), Eq(q, "a"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ns []int
+	if err := eval.ToGo(types.List(answers...), &ns); err != (eval.WrongArg{Val: "a"}) {
+		t.Errorf("expected an error for the wrong type of the answer, got %v", err)
+	}
+}
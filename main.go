@@ -7,10 +7,8 @@ import (
 	"log"
 	"os"
 
-	"github.com/twolodzko/kanren/envir"
 	"github.com/twolodzko/kanren/eval"
 	"github.com/twolodzko/kanren/repl"
-	"github.com/twolodzko/kanren/types"
 )
 
 const prompt string = "> "
@@ -19,33 +17,42 @@ func main() {
 	var (
 		showHelp bool
 		keepRepl bool
+		debug    bool
+		opts     eval.Options
 	)
 
 	flag.BoolVar(&showHelp, "help", false, "show help")
-	flag.BoolVar(&eval.Debug, "debug", false, "run in debug mode")
-	flag.BoolVar(&types.Pretty, "pretty", false, "prettify the outputs")
+	flag.BoolVar(&debug, "debug", false, "run in debug mode")
+	flag.BoolVar(&opts.Pretty, "pretty", false, "prettify the outputs")
 	flag.BoolVar(&keepRepl, "keep", false, "open REPL after evaluating files")
+	flag.BoolVar(&opts.NoOccursCheck, "no-occurs", false, "unify without the occurs check")
+	flag.DurationVar(&opts.Timeout, "timeout", 0, "time limit for a single run, e.g. 10s (no limit by default)")
+	flag.IntVar(&opts.MaxSteps, "max-steps", 0, "maximal number of the goals queried and the expressions evaluated in a single run (no limit by default)")
+	flag.IntVar(&opts.MaxDepth, "max-depth", 500000, "maximal depth of the nested evaluations, no limit when 0")
 	flag.Parse()
 
 	if showHelp {
 		printHelp()
 		return
 	}
+	if debug {
+		opts.Debug = os.Stdout
+	}
 
-	env := eval.DefaultEnv()
+	in := eval.NewInterpreter(opts)
 	if flag.NArg() > 0 {
-		evalFiles(env, flag.Args())
+		evalFiles(in, flag.Args())
 		if !keepRepl {
 			return
 		}
 	}
-	startRepl(env)
+	startRepl(in)
 }
 
-func evalFiles(env *envir.Env, paths []string) {
+func evalFiles(in *eval.Interpreter, paths []string) {
 	var last any = nil
 	for _, path := range paths {
-		sexprs, err := eval.LoadEval(path, env)
+		sexprs, err := in.Load(path)
 		if err != nil {
 			log.Fatalf("ERROR: %v\n", err)
 		}
@@ -53,24 +60,28 @@ func evalFiles(env *envir.Env, paths []string) {
 			last = sexprs[len(sexprs)-1]
 		}
 	}
-	fmt.Printf("%s\n", types.ToString(last))
+	print(in.Stdout(), in.ToString(last))
 }
 
-func startRepl(env *envir.Env) {
-	repl := repl.NewRepl(os.Stdin, env)
+func startRepl(in *eval.Interpreter) {
+	repl := repl.NewRepl(os.Stdin, in.Env())
 
-	fmt.Println("Press ^C to exit.")
-	fmt.Println()
+	fmt.Fprintln(in.Stdout(), "Press ^C to exit.")
+	fmt.Fprintln(in.Stdout())
 
 	for {
-		fmt.Printf("%s", prompt)
+		fmt.Fprintf(in.Stdout(), "%s", prompt)
 		objs, err := repl.Repl()
+		if err == io.EOF {
+			fmt.Fprintln(in.Stdout())
+			return
+		}
 		if err != nil {
-			print(fmt.Sprintf("ERROR: %s", err))
+			print(in.Stderr(), fmt.Sprintf("ERROR: %s", err))
 			continue
 		}
 		for _, obj := range objs {
-			print(types.ToString(obj))
+			print(in.Stdout(), in.ToString(obj))
 		}
 	}
 }
@@ -82,8 +93,8 @@ func printHelp() {
 	flag.PrintDefaults()
 }
 
-func print(msg string) {
-	_, err := io.WriteString(os.Stdout, fmt.Sprintf("%s\n", msg))
+func print(w io.Writer, msg string) {
+	_, err := io.WriteString(w, fmt.Sprintf("%s\n", msg))
 	if err != nil {
 		log.Fatal(err)
 	}
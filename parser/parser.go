@@ -4,8 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"regexp"
-	"strconv"
 	"unicode"
 
 	"github.com/twolodzko/kanren/types"
@@ -32,6 +30,17 @@ func (p *Parser) Following() rune {
 	return p.str[p.pos+1]
 }
 
+// Check if the characters after the current one start with the prefix
+func (p *Parser) followedBy(prefix string) bool {
+	rest := p.str[p.pos+1:]
+	for i, r := range []rune(prefix) {
+		if i >= len(rest) || rest[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *Parser) Read() ([]any, error) {
 	var sexprs []any
 	for p.HasNext() {
@@ -77,12 +86,22 @@ func (p *Parser) Sexpr() (any, error) {
 			return nil, fmt.Errorf("unexpected closing bracket")
 		case '"':
 			return p.readString()
+		case '#':
+			if p.pos+1 < len(p.str) && p.Following() == '\\' {
+				return p.readChar()
+			}
+			if p.pos+1 < len(p.str) && p.Following() == '(' {
+				return p.readVector()
+			}
+			if p.followedBy("u8(") {
+				return p.readBytevector()
+			}
+			return p.readAtom()
 		case ';':
 			p.skipLine()
 		case '_':
-			p.pos++
-			if p.Head() == '.' {
-				p.pos++
+			if p.pos+1 < len(p.str) && p.Following() == '.' {
+				p.pos += 2
 				val, err := p.readAtom()
 				if err != nil {
 					return nil, err
@@ -93,6 +112,16 @@ func (p *Parser) Sexpr() (any, error) {
 				}
 				return types.Free(id), nil
 			}
+			return p.readAtom()
+		case '.':
+			if p.isDecimal() {
+				return p.readAtom()
+			}
+			if !p.isEllipsis() {
+				return nil, fmt.Errorf("unexpected dot")
+			}
+			p.pos += len(ellipsis)
+			return types.Symbol(ellipsis), nil
 		default:
 			return p.readAtom()
 		}
@@ -103,7 +132,7 @@ func (p *Parser) Sexpr() (any, error) {
 func (p *Parser) readAtom() (any, error) {
 	var runes []rune
 	for p.HasNext() {
-		if isWordBoundary(p.Head()) {
+		if isWordBoundary(p.Head()) && !(p.Head() == '.' && isNumberPrefix(runes)) {
 			break
 		}
 		runes = append(runes, p.Head())
@@ -122,19 +151,14 @@ func parseAtom(str string) (any, error) {
 		return true, nil
 	case str == "#f":
 		return false, nil
-	case isInt(str):
-		val, err := strconv.Atoi(str)
-		return val, err
 	default:
+		if num, ok := types.ParseNumber(str); ok {
+			return num, nil
+		}
 		return types.Symbol(str), nil
 	}
 }
 
-func isInt(str string) bool {
-	matched, _ := regexp.MatchString(`^[+-]?\d+$`, str)
-	return matched
-}
-
 func (p *Parser) readPair() (any, error) {
 	p.pos++
 	var acc []any
@@ -145,7 +169,7 @@ func (p *Parser) readPair() (any, error) {
 		case isClosingBracket(p.Head()):
 			p.pos++
 			return types.List(acc...), nil
-		case p.Head() == '.':
+		case p.Head() == '.' && !p.isEllipsis() && !p.isDecimal():
 			p.pos++
 			tail, err := p.Sexpr()
 			if err != nil {
@@ -173,19 +197,137 @@ func (p *Parser) readString() (string, error) {
 	p.pos++
 	var runes []rune
 	for p.HasNext() {
-		if p.Head() == '\\' && p.HasNext() && p.Following() == '"' {
-			runes = append(runes, '"')
-			p.pos = p.pos + 2
-			continue
-		}
-		if p.Head() == '"' {
+		switch p.Head() {
+		case '"':
 			p.pos++
 			return string(runes), nil
+		case '\\':
+			p.pos++
+			r, err := p.readEscape()
+			if err != nil {
+				return "", err
+			}
+			if r >= 0 {
+				runes = append(runes, r)
+			}
+		default:
+			runes = append(runes, p.Head())
+			p.pos++
+		}
+	}
+	return "", errors.New("string was not closed with \"")
+}
+
+// Read the escape sequence following the backslash in the string, like `\n` or `\x41;`,
+// for the line continuation `\<newline>` the whitespace around it is skipped and -1 is returned
+func (p *Parser) readEscape() (rune, error) {
+	if !p.HasNext() {
+		return 0, errors.New("string was not closed with \"")
+	}
+	c := p.Head()
+	p.pos++
+	if r, ok := types.Unescape(c); ok {
+		return r, nil
+	}
+	switch {
+	case c == 'x':
+		var hex []rune
+		for p.HasNext() && p.Head() != ';' && p.Head() != '"' {
+			hex = append(hex, p.Head())
+			p.pos++
+		}
+		if !p.HasNext() || p.Head() != ';' {
+			return 0, fmt.Errorf("invalid escape sequence: \\x%s", string(hex))
+		}
+		p.pos++
+		r, ok := types.ParseChar("x" + string(hex))
+		if !ok || len(hex) == 0 {
+			return 0, fmt.Errorf("invalid escape sequence: \\x%s;", string(hex))
 		}
+		return rune(r), nil
+	case c == '\n' || unicode.IsSpace(c):
+		// the line continuation
+		p.pos--
+		for p.HasNext() && p.Head() != '\n' && unicode.IsSpace(p.Head()) {
+			p.pos++
+		}
+		if !p.HasNext() || p.Head() != '\n' {
+			return 0, errors.New("invalid escape sequence: \\ followed by whitespace")
+		}
+		p.pos++
+		for p.HasNext() && p.Head() != '\n' && unicode.IsSpace(p.Head()) {
+			p.pos++
+		}
+		return -1, nil
+	default:
+		return 0, fmt.Errorf("invalid escape sequence: \\%c", c)
+	}
+}
+
+// Read the vector literal, like `#(1 2 3)`
+func (p *Parser) readVector() (any, error) {
+	p.pos++
+	elems, err := p.readElems()
+	if err != nil {
+		return nil, err
+	}
+	return &types.Vector{Elems: elems, Const: true}, nil
+}
+
+// Read the bytevector literal, like `#u8(1 2 3)`
+func (p *Parser) readBytevector() (any, error) {
+	p.pos += 3
+	elems, err := p.readElems()
+	if err != nil {
+		return nil, err
+	}
+	var acc []byte
+	for _, e := range elems {
+		n, ok := e.(int)
+		if !ok || n < 0 || n > 255 {
+			return nil, fmt.Errorf("invalid byte: %v", types.ToString(e))
+		}
+		acc = append(acc, byte(n))
+	}
+	return &types.Bytevector{Bytes: acc, Const: true}, nil
+}
+
+// Read the elements of the list, that cannot contain a dot
+func (p *Parser) readElems() ([]any, error) {
+	val, err := p.readPair()
+	if err != nil {
+		return nil, err
+	}
+	var elems []any
+	for head := val; head != nil; {
+		pair, ok := head.(types.Pair)
+		if !ok {
+			return nil, errors.New("vector cannot contain a dot")
+		}
+		elems = append(elems, pair.This)
+		head = pair.Next
+	}
+	return elems, nil
+}
+
+// Read the character literal, like `#\a`, `#\(`, or `#\newline`
+func (p *Parser) readChar() (any, error) {
+	p.pos += 2
+	if !p.HasNext() {
+		return nil, errors.New("invalid character: #\\")
+	}
+	// the first character can be a delimiter, like in `#\(`
+	runes := []rune{p.Head()}
+	p.pos++
+	for p.HasNext() && !isWordBoundary(p.Head()) {
 		runes = append(runes, p.Head())
 		p.pos++
 	}
-	return "", errors.New("string was not closed with \"")
+	c, ok := types.ParseChar(string(runes))
+	if !ok {
+		return nil, fmt.Errorf("invalid character: #\\%s", string(runes))
+	}
+	return c, nil
 }
 
 func (p *Parser) skipLine() {
@@ -198,6 +340,19 @@ func (p *Parser) skipLine() {
 	}
 }
 
+const ellipsis = "..."
+
+// Check if the ellipsis used in the macros is at the current position
+func (p *Parser) isEllipsis() bool {
+	end := p.pos + len(ellipsis)
+	return end <= len(p.str) && string(p.str[p.pos:end]) == ellipsis
+}
+
+// Check if the decimal number like `.5` starts at the current position
+func (p *Parser) isDecimal() bool {
+	return p.pos+1 < len(p.str) && unicode.IsDigit(p.str[p.pos+1])
+}
+
 func (p *Parser) skipSpace() {
 	for p.HasNext() {
 		if !unicode.IsSpace(p.Head()) {
@@ -223,6 +378,20 @@ func isWordBoundary(r rune) bool {
 	return unicode.IsSpace(r) || isOpeningBracket(r) || isClosingBracket(r) || r == '\'' || r == '.'
 }
 
+// The dot is a part of the number, like `1.5`, `.5`, or `+inf.0`
+func isNumberPrefix(runes []rune) bool {
+	switch string(runes) {
+	case "+inf", "-inf", "+nan", "-nan":
+		return true
+	}
+	for i, r := range runes {
+		if !unicode.IsDigit(r) && !(i == 0 && (r == '+' || r == '-')) {
+			return false
+		}
+	}
+	return true
+}
+
 func isOpeningBracket(r rune) bool {
 	return r == '(' || r == '['
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"math"
 	"reflect"
 	"testing"
 
@@ -32,6 +33,26 @@ func TestParse(t *testing.T) {
 		{"  \n\ta", types.Symbol("a")},
 		{"\n  \t\n(\n   a\t\n)  ", types.List(types.Symbol("a"))},
 		{"(list 1 2 ;; a comment\n3)", types.List(types.Symbol("list"), 1, 2, 3)},
+		{"(_ x ...)", types.List(types.Symbol("_"), types.Symbol("x"), types.Symbol("..."))},
+		{"(a ... . b)", types.Cons(types.Symbol("a"), types.Symbol("..."), types.Symbol("b"))},
+		{"_.1", types.Free(1)},
+		{`#\a`, types.Char('a')},
+		{`#\(`, types.Char('(')},
+		{`#\ `, types.Char(' ')},
+		{`#\space`, types.Char(' ')},
+		{`#\newline`, types.Char('\n')},
+		{`#\x41`, types.Char('A')},
+		{`(#\) #\;)`, types.List(types.Char(')'), types.Char(';'))},
+		{`"a\"b"`, "a\"b"},
+		{`"a\\b"`, "a\\b"},
+		{`"\n\t\r\a"`, "\n\t\r\a"},
+		{`"\x41;\x3bb;"`, "Aλ"},
+		{"\"a \\  \n   b\"", "a b"},
+		{"#(1 (a) #(2))", types.Freeze(types.NewVector(1, types.List(types.Symbol("a")), types.NewVector(2)))},
+		{"#()", types.Freeze(types.NewVector())},
+		{"#u8(0 1 255)", &types.Bytevector{Bytes: []byte{0, 1, 255}, Const: true}},
+		{"1e400", math.Inf(1)},
+		{"-1e400", math.Inf(-1)},
 	}
 
 	for _, tt := range testCases {
@@ -52,6 +73,11 @@ func TestParseAndPrint(t *testing.T) {
 		"(1 (2 3))",
 		"((1 2) 3)",
 		"((1) (((2)) 3))",
+		"(1.5 -2.0 0.5 7/2 -1/3 123456789012345678901234567890)",
+		"(+inf.0 -inf.0 +nan.0 . 2.5)",
+		`(#\a #\space #\newline #\( "a\"b\\c\n")`,
+		`#(1 #(2 "a") (#\b) #())`,
+		"(#u8(1 2) #u8())",
 	}
 
 	for _, input := range testCases {
@@ -98,6 +124,15 @@ func TestParseExpectError(t *testing.T) {
 		{"(a", "list was not closed with closing bracket"},
 		{"(lorem ipsum", "list was not closed with closing bracket"},
 		{"lorem ipsum)", "unexpected closing bracket"},
+		{`"abc`, "string was not closed with \""},
+		{`"\q"`, `invalid escape sequence: \q`},
+		{`"\x41"`, `invalid escape sequence: \x41`},
+		{`"\xzz;"`, `invalid escape sequence: \xzz;`},
+		{`#\foo`, `invalid character: #\foo`},
+		{"#(1 2", "list was not closed with closing bracket"},
+		{"#(1 . 2)", "vector cannot contain a dot"},
+		{"#u8(1 256)", "invalid byte: 256"},
+		{"#u8(a)", "invalid byte: a"},
 	}
 	for _, tt := range testCases {
 		parser := NewParser(tt.input)
@@ -11,6 +11,7 @@ type blockReader struct {
 	openBlocksCount int
 	isQuoted        bool
 	isEscaped       bool
+	isChar          bool
 }
 
 func (repl *Repl) read() (string, error) {
@@ -19,7 +20,7 @@ func (repl *Repl) read() (string, error) {
 		out, line string
 	)
 
-	reader := blockReader{repl.reader, 0, false, false}
+	reader := blockReader{repl.reader, 0, false, false, false}
 
 	for {
 		line, err = reader.ReadString('\n')
@@ -46,20 +47,28 @@ func (repl *Repl) read() (string, error) {
 }
 
 func (reader *blockReader) shouldStop(line string) bool {
+	var prev rune
 	for _, r := range line {
 
-		switch r {
-		case '"':
-			if !reader.isEscaped {
-				reader.isQuoted = !reader.isQuoted
+		switch {
+		// character literal, like #\( - it is not a bracket
+		case reader.isChar:
+			reader.isChar = false
+		case reader.isQuoted:
+			if r == '"' && !reader.isEscaped {
+				reader.isQuoted = false
 			}
+		case r == '"':
+			reader.isQuoted = true
 		// comment - ignore rest of the line
-		case ';':
+		case r == ';':
 			return false
+		case r == '\\' && prev == '#':
+			reader.isChar = true
 		// list - wait till closing bracket
-		case '(', '[':
+		case r == '(' || r == '[':
 			reader.openBlocksCount++
-		case ')', ']':
+		case r == ')' || r == ']':
 			reader.openBlocksCount--
 
 			if reader.openBlocksCount <= 0 {
@@ -72,6 +81,7 @@ func (reader *blockReader) shouldStop(line string) bool {
 		} else {
 			reader.isEscaped = false
 		}
+		prev = r
 	}
 
 	return reader.openBlocksCount <= 0 && !reader.isQuoted
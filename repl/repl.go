@@ -3,6 +3,7 @@ package repl
 import (
 	"bufio"
 	"io"
+	"strings"
 
 	"github.com/twolodzko/kanren/envir"
 	"github.com/twolodzko/kanren/eval"
@@ -17,9 +18,11 @@ func NewRepl(in io.Reader, env *envir.Env) *Repl {
 	return &Repl{bufio.NewReader(in), env}
 }
 
+// Read and evaluate the next command, io.EOF is returned when there are no more commands
 func (repl *Repl) Repl() ([]any, error) {
 	cmd, err := repl.read()
-	if err != nil {
+	// the last command does not need to end with a newline
+	if err != nil && !(err == io.EOF && strings.TrimSpace(cmd) != "") {
 		return nil, err
 	}
 	objs, env, err := eval.EvalString(cmd, repl.env)
@@ -1,6 +1,7 @@
 package repl
 
 import (
+	"io"
 	"strings"
 	"testing"
 
@@ -27,3 +28,35 @@ func TestRead_InvalidInput(t *testing.T) {
 		}
 	}
 }
+
+func TestRead_Literals(t *testing.T) {
+	var testCases = []string{
+		"(list #\\( #\\[)",
+		"(list \"(\" \")\")",
+		"(list \"a;b\" #\\;)",
+		"(list \"\\\"(\")",
+	}
+
+	for _, input := range testCases {
+		repl := NewRepl(strings.NewReader(input+"\n(rest)"), envir.NewEnv())
+		result, err := repl.read()
+		if err != nil || result != input+"\n" {
+			t.Errorf("for %s expected the single command, got '%s' (%v)", input, result, err)
+		}
+	}
+}
+
+func TestRepl_LastCommand(t *testing.T) {
+	env := envir.NewEnv()
+	env.Set("x", 42)
+	repl := NewRepl(strings.NewReader("x\nx"), env)
+	for range 2 {
+		result, err := repl.Repl()
+		if err != nil || len(result) != 1 || result[0] != 42 {
+			t.Errorf("expected [42], got %v (%v)", result, err)
+		}
+	}
+	if _, err := repl.Repl(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
@@ -4,10 +4,7 @@ import (
 	"fmt"
 )
 
-type (
-	Number = int
-	Symbol string
-)
+type Symbol string
 
 func IsTrue(s any) bool {
 	switch val := s.(type) {
@@ -29,7 +26,9 @@ func ToString(val any) string {
 			return "#f"
 		}
 	case string:
-		return fmt.Sprintf("\"%s\"", val)
+		return quoteString(val)
+	case float64:
+		return FormatNumber(val, 10)
 	default:
 		return fmt.Sprintf("%v", val)
 	}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bytevector is the fixed-size sequence of bytes, its elements can be changed,
+// so it is shared by the pointer
+type Bytevector struct {
+	Bytes []byte
+	// the constant bytevectors, like the literals, cannot be changed
+	Const bool
+}
+
+func NewBytevector(bytes ...byte) *Bytevector {
+	return &Bytevector{Bytes: bytes}
+}
+
+func (b *Bytevector) String() string {
+	var acc []string
+	for _, x := range b.Bytes {
+		acc = append(acc, fmt.Sprintf("%d", x))
+	}
+	return fmt.Sprintf("#u8(%s)", strings.Join(acc, " "))
+}
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Char rune
+
+// The names of the characters, as in `#\newline`
+var charNames = map[string]rune{
+	"alarm":     '\a',
+	"backspace": '\b',
+	"delete":    '\x7f',
+	"escape":    '\x1b',
+	"newline":   '\n',
+	"null":      '\x00',
+	"return":    '\r',
+	"space":     ' ',
+	"tab":       '\t',
+}
+
+// Parse the character written after `#\`, it is the character itself,
+// its name, like `newline`, or its hex code, like `x41`
+func ParseChar(str string) (Char, bool) {
+	if utf8.RuneCountInString(str) == 1 {
+		r, _ := utf8.DecodeRuneInString(str)
+		return Char(r), true
+	}
+	if r, ok := charNames[str]; ok {
+		return Char(r), true
+	}
+	if hex, ok := strings.CutPrefix(str, "x"); ok {
+		if code, err := strconv.ParseUint(hex, 16, 32); err == nil && utf8.ValidRune(rune(code)) {
+			return Char(code), true
+		}
+	}
+	return 0, false
+}
+
+func (c Char) String() string {
+	for name, r := range charNames {
+		if rune(c) == r {
+			return fmt.Sprintf("#\\%s", name)
+		}
+	}
+	if !unicode.IsPrint(rune(c)) {
+		return fmt.Sprintf("#\\x%x", rune(c))
+	}
+	return fmt.Sprintf("#\\%c", rune(c))
+}
+
+// The escape sequences used in the strings, as in `"a\nb"`
+var escapes = map[rune]rune{
+	'a':  '\a',
+	'b':  '\b',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'"':  '"',
+	'\\': '\\',
+	'|':  '|',
+}
+
+// The character for the escape sequence `\c`
+func Unescape(c rune) (rune, bool) {
+	r, ok := escapes[c]
+	return r, ok
+}
+
+// Quote the string, escaping the special characters
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteRune('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString("\\n")
+		case '\t':
+			b.WriteString("\\t")
+		case '\r':
+			b.WriteString("\\r")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune('"')
+	return b.String()
+}
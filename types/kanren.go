@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"sync/atomic"
 )
 
 var Pretty = false
@@ -12,33 +13,92 @@ type (
 	// so that when comparing them we compare their addrses, what makes
 	// them unique (variables with same names are not necessary the same).
 	// In the original implementation they are vectors, and Scheme's eq?
-	// for vectors compares their addresses.
-	variableName string
-	Variable     = *variableName
-	Free         int
+	// for vectors compares their addresses. The unique id is used
+	// as a key when storing them in the maps.
+	variable struct {
+		name string
+		id   uint64
+	}
+	Variable = *variable
+	Free     int
+	// Cyclic term, possible when unifying without the occurs check,
+	// printed using the datum labels `#0=(a . #0#)`
+	Labelled struct {
+		Label int
+		Value any
+	}
+	// Reference to the labelled term that contains it
+	Backref int
 )
 
+var variables atomic.Uint64
+
 func NewVariable(name string) Variable {
-	v := variableName(name)
-	return &v
+	return &variable{name, variables.Add(1)}
 }
 
-func (v variableName) String() string {
+// The unique identifier of the variable
+func (v *variable) Id() uint64 {
+	return v.id
+}
+
+func (v variable) String() string {
 	if Pretty {
-		// see: https://stackoverflow.com/questions/13559276/can-i-write-italics-to-the-python-shell/13559470#13559470
-		return fmt.Sprintf("\x1B[3m%s\x1B[0m", string(v))
+		return prettyVariable(v).String()
 	}
-	return string(v)
+	return v.name
 }
 
 func (f Free) String() string {
 	if Pretty {
-		// see: https://stackoverflow.com/questions/60064647/how-do-i-use-subscript-digits-in-my-c-program
-		return fmt.Sprintf("\u208B%s", lowerDigits(int(f)))
+		return prettyFree(f).String()
 	}
 	return fmt.Sprintf("_.%d", f)
 }
 
+// The variables printed in the pretty form
+type (
+	prettyVariable variable
+	prettyFree     Free
+)
+
+func (v prettyVariable) String() string {
+	// see: https://stackoverflow.com/questions/13559276/can-i-write-italics-to-the-python-shell/13559470#13559470
+	return fmt.Sprintf("\x1B[3m%s\x1B[0m", v.name)
+}
+
+func (f prettyFree) String() string {
+	// see: https://stackoverflow.com/questions/60064647/how-do-i-use-subscript-digits-in-my-c-program
+	return fmt.Sprintf("\u208B%s", lowerDigits(int(f)))
+}
+
+// Format the value, when pretty is true the variables are printed
+// in the pretty form, even if Pretty is not set
+func Format(val any, pretty bool) string {
+	if pretty {
+		val, _ = labelCycles(val)
+		val = prettify(val)
+	}
+	return ToString(val)
+}
+
+func prettify(val any) any {
+	switch val := val.(type) {
+	case Variable:
+		return prettyVariable(*val)
+	case Free:
+		return prettyFree(val)
+	case Labelled:
+		return Labelled{val.Label, prettify(val.Value)}
+	case Pair:
+		return Cons(val.Map(prettify)...)
+	case *Vector:
+		return val.Map(prettify)
+	default:
+		return val
+	}
+}
+
 func lowerDigits(num int) string {
 	var acc []rune
 	n := digits(num)
@@ -59,3 +119,11 @@ func digits(n int) []int {
 	}
 	return acc
 }
+
+func (l Labelled) String() string {
+	return fmt.Sprintf("#%d=%s", l.Label, ToString(l.Value))
+}
+
+func (b Backref) String() string {
+	return fmt.Sprintf("#%d#", int(b))
+}
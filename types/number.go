@@ -0,0 +1,429 @@
+package types
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The numbers are the exact integers, stored as int and promoted to *big.Int
+// when they overflow, the exact rationals stored as *big.Rat, and the inexact
+// numbers stored as float64. The results of the operations on the exact numbers
+// are normalized, so the rationals with the denominator 1 become the integers,
+// and the integers that fit into int are stored as int.
+
+// Numbers ordered by their rank, the operations on two numbers
+// are carried in the higher rank of the two
+const (
+	fixnum = iota
+	bignum
+	rational
+	inexact
+)
+
+func rank(v any) int {
+	switch v.(type) {
+	case int:
+		return fixnum
+	case *big.Int:
+		return bignum
+	case *big.Rat:
+		return rational
+	default:
+		return inexact
+	}
+}
+
+func IsNumber(v any) bool {
+	switch v.(type) {
+	case int, *big.Int, *big.Rat, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func IsExact(v any) bool {
+	_, ok := v.(float64)
+	return IsNumber(v) && !ok
+}
+
+func IsInteger(v any) bool {
+	switch v := v.(type) {
+	case int, *big.Int:
+		return true
+	case float64:
+		return v == math.Trunc(v) && !math.IsInf(v, 0)
+	default:
+		return false
+	}
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case *big.Int:
+		if v.IsInt64() && v.Int64() >= math.MinInt && v.Int64() <= math.MaxInt {
+			return int(v.Int64())
+		}
+	case *big.Rat:
+		if v.IsInt() {
+			return normalize(new(big.Int).Set(v.Num()))
+		}
+	}
+	return v
+}
+
+func toRat(v any) *big.Rat {
+	switch v := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(v))
+	case *big.Int:
+		return new(big.Rat).SetInt(v)
+	case *big.Rat:
+		return v
+	default:
+		return nil
+	}
+}
+
+func toBig(v any) *big.Int {
+	switch v := v.(type) {
+	case int:
+		return big.NewInt(int64(v))
+	case *big.Int:
+		return v
+	default:
+		return nil
+	}
+}
+
+// Copy the big number as the Scheme number, it is normalized,
+// so the big integers that fit into int become int
+func FromBig(v any) any {
+	switch v := v.(type) {
+	case *big.Int:
+		return normalize(new(big.Int).Set(v))
+	case *big.Rat:
+		return normalize(new(big.Rat).Set(v))
+	default:
+		return v
+	}
+}
+
+// Convert the exact integer to a new big integer
+func ToBigInt(v any) (*big.Int, bool) {
+	switch v := v.(type) {
+	case int:
+		return big.NewInt(int64(v)), true
+	case *big.Int:
+		return new(big.Int).Set(v), true
+	default:
+		return nil, false
+	}
+}
+
+// Convert the exact number to a new big rational
+func ToBigRat(v any) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case int, *big.Int:
+		return toRat(v), true
+	case *big.Rat:
+		return new(big.Rat).Set(v), true
+	default:
+		return nil, false
+	}
+}
+
+// The number of bits of the exact number, or of the larger of the numerator
+// and the denominator of the rational, it is zero for the inexact numbers
+func BitLen(v any) int {
+	switch v := v.(type) {
+	case int:
+		return bits.Len(uint(max(v, -v)))
+	case *big.Int:
+		return v.BitLen()
+	case *big.Rat:
+		return max(v.Num().BitLen(), v.Denom().BitLen())
+	default:
+		return 0
+	}
+}
+
+// Convert the number to the inexact number
+func ToInexact(v any) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case *big.Rat:
+		f, _ := v.Float64()
+		return f
+	default:
+		return v.(float64)
+	}
+}
+
+// Apply the operation for the fixnums, when it does not overflow, otherwise
+// for the exact or inexact numbers, depending on the rank of the arguments
+func arith(
+	a, b any,
+	fix func(a, b int) (int, bool),
+	exact func(a, b *big.Rat) *big.Rat,
+	float func(a, b float64) float64,
+) any {
+	if x, ok := a.(int); ok {
+		if y, ok := b.(int); ok {
+			if z, ok := fix(x, y); ok {
+				return z
+			}
+		}
+	}
+	if max(rank(a), rank(b)) == inexact {
+		return float(ToInexact(a), ToInexact(b))
+	}
+	return normalize(exact(toRat(a), toRat(b)))
+}
+
+func Add(a, b any) any {
+	return arith(a, b,
+		func(a, b int) (int, bool) {
+			c := a + b
+			return c, (c > a) == (b > 0)
+		},
+		func(a, b *big.Rat) *big.Rat { return new(big.Rat).Add(a, b) },
+		func(a, b float64) float64 { return a + b },
+	)
+}
+
+func Sub(a, b any) any {
+	return arith(a, b,
+		func(a, b int) (int, bool) {
+			c := a - b
+			return c, (c < a) == (b > 0)
+		},
+		func(a, b *big.Rat) *big.Rat { return new(big.Rat).Sub(a, b) },
+		func(a, b float64) float64 { return a - b },
+	)
+}
+
+func Mul(a, b any) any {
+	return arith(a, b,
+		func(a, b int) (int, bool) {
+			if a == 0 || b == 0 {
+				return 0, true
+			}
+			c := a * b
+			return c, c/b == a && !(a == -1 && b == math.MinInt) && !(b == -1 && a == math.MinInt)
+		},
+		func(a, b *big.Rat) *big.Rat { return new(big.Rat).Mul(a, b) },
+		func(a, b float64) float64 { return a * b },
+	)
+}
+
+// Divide the numbers, the exact integers that do not divide evenly give the rational,
+// the exact divisor cannot be zero
+func Div(a, b any) any {
+	return arith(a, b,
+		func(a, b int) (int, bool) {
+			if b == 0 || a%b != 0 || (a == math.MinInt && b == -1) {
+				return 0, false
+			}
+			return a / b, true
+		},
+		func(a, b *big.Rat) *big.Rat { return new(big.Rat).Quo(a, b) },
+		func(a, b float64) float64 { return a / b },
+	)
+}
+
+// The remainder of the division of the integers, it has the sign of the dividend,
+// false is returned for the numbers other than integers, the exact divisor cannot be zero
+func Remainder(a, b any) (any, bool) {
+	if !IsInteger(a) || !IsInteger(b) {
+		return nil, false
+	}
+	if x, ok := a.(int); ok {
+		if y, ok := b.(int); ok {
+			return x % y, true
+		}
+	}
+	if max(rank(a), rank(b)) == inexact {
+		return math.Mod(ToInexact(a), ToInexact(b)), true
+	}
+	return normalize(new(big.Int).Rem(toBig(a), toBig(b))), true
+}
+
+// Compare the numbers, return -1, 0, or +1, the comparisons
+// with NaN are unordered and false is returned
+func Compare(a, b any) (int, bool) {
+	if x, ok := a.(int); ok {
+		if y, ok := b.(int); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	if max(rank(a), rank(b)) == inexact {
+		x, y := ToInexact(a), ToInexact(b)
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		case x == y:
+			return 0, true
+		default:
+			return 0, false
+		}
+	}
+	return toRat(a).Cmp(toRat(b)), true
+}
+
+// Check if the values are the same, the numbers are the same
+// if they are equal and have the same exactness, and the bytevectors
+// if they have the same bytes
+func Eqv(a, b any) bool {
+	if a == b {
+		return true
+	}
+	if x, ok := a.(*Bytevector); ok {
+		y, ok := b.(*Bytevector)
+		return ok && bytes.Equal(x.Bytes, y.Bytes)
+	}
+	if IsNumber(a) && IsNumber(b) && IsExact(a) == IsExact(b) {
+		c, ok := Compare(a, b)
+		return ok && c == 0
+	}
+	return false
+}
+
+// The largest integer not larger than the number
+func Floor(v any) any {
+	switch v := v.(type) {
+	case *big.Rat:
+		// the denominator is positive, so the Euclidean division rounds down
+		return normalize(new(big.Int).Div(v.Num(), v.Denom()))
+	case float64:
+		return math.Floor(v)
+	default:
+		return v
+	}
+}
+
+// The square root of the number, it is exact for the exact numbers
+// that are the squares of the exact numbers, false is returned
+// for the negative numbers
+func Sqrt(v any) (any, bool) {
+	if c, _ := Compare(v, 0); c < 0 {
+		return nil, false
+	}
+	switch v := v.(type) {
+	case int, *big.Int:
+		n := toBig(v)
+		root := new(big.Int).Sqrt(n)
+		if new(big.Int).Mul(root, root).Cmp(n) == 0 {
+			return normalize(root), true
+		}
+	case *big.Rat:
+		num, ok := Sqrt(normalize(new(big.Int).Set(v.Num())))
+		den, _ := Sqrt(normalize(new(big.Int).Set(v.Denom())))
+		if ok && IsExact(num) && IsExact(den) {
+			return Div(num, den), true
+		}
+	}
+	return math.Sqrt(ToInexact(v)), true
+}
+
+// Raise the base to the power, the result is exact for the exact base and the exact
+// integer exponent, the exact zero cannot be raised to the negative power
+func Expt(base, exp any) any {
+	if IsExact(base) && (rank(exp) == fixnum || rank(exp) == bignum) {
+		e := toBig(exp)
+		if e.Sign() < 0 {
+			return Div(1, Expt(base, normalize(new(big.Int).Neg(e))))
+		}
+		r := toRat(base)
+		num := new(big.Int).Exp(r.Num(), e, nil)
+		den := new(big.Int).Exp(r.Denom(), e, nil)
+		return normalize(new(big.Rat).SetFrac(num, den))
+	}
+	return math.Pow(ToInexact(base), ToInexact(exp))
+}
+
+// Format the number in the radix, the inexact numbers are always decimal
+func FormatNumber(v any, radix int) string {
+	switch v := v.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), radix)
+	case *big.Int:
+		return v.Text(radix)
+	case *big.Rat:
+		return v.Num().Text(radix) + "/" + v.Denom().Text(radix)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "+nan.0"
+		case math.IsInf(v, 1):
+			return "+inf.0"
+		case math.IsInf(v, -1):
+			return "-inf.0"
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			// the inexact integers are marked with the decimal point
+			s += ".0"
+		}
+		return s
+	default:
+		return ""
+	}
+}
+
+var (
+	integerRe  = regexp.MustCompile(`^[+-]?\d+$`)
+	rationalRe = regexp.MustCompile(`^[+-]?\d+/\d+$`)
+	decimalRe  = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)(e[+-]?\d+)?$`)
+)
+
+// Parse the number, false is returned if the string is not a number
+func ParseNumber(str string) (any, bool) {
+	switch {
+	case integerRe.MatchString(str):
+		n, ok := new(big.Int).SetString(str, 10)
+		if !ok {
+			return nil, false
+		}
+		return normalize(n), true
+	case rationalRe.MatchString(str):
+		r, ok := new(big.Rat).SetString(str)
+		if !ok {
+			// zero denominator
+			return nil, false
+		}
+		return normalize(r), true
+	case decimalRe.MatchString(str):
+		// the numbers out of the range are read as the infinities
+		f, err := strconv.ParseFloat(str, 64)
+		return f, err == nil || errors.Is(err, strconv.ErrRange)
+	}
+	switch str {
+	case "+inf.0":
+		return math.Inf(1), true
+	case "-inf.0":
+		return math.Inf(-1), true
+	case "+nan.0", "-nan.0":
+		return math.NaN(), true
+	}
+	return nil, false
+}
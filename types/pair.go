@@ -73,7 +73,7 @@ func (p Pair) ToString() string {
 			acc = append(acc, ToString(p.This))
 			head = p.Next
 		default:
-			return fmt.Sprintf("%v . %v", strings.Join(acc, " "), head)
+			return fmt.Sprintf("%v . %v", strings.Join(acc, " "), ToString(head))
 		}
 	}
 	return strings.Join(acc, " ")
@@ -38,6 +38,18 @@ func TestString(t *testing.T) {
 			List(true).(Pair),
 			"(#t)",
 		},
+		{
+			Cons(1.5, 2.0),
+			"(1.5 . 2.0)",
+		},
+		{
+			Cons(1, "a\tb"),
+			`(1 . "a\tb")`,
+		},
+		{
+			Cons(1, true),
+			"(1 . #t)",
+		},
 	}
 	for _, tt := range testCases {
 		result := tt.input.String()
@@ -68,3 +80,18 @@ func TestVariables(t *testing.T) {
 		t.Error("the variables should differ ragerdless of same names")
 	}
 }
+
+func TestFreezeCycles(t *testing.T) {
+	v := NewVector(1, 2)
+	v.Elems[0] = List(v)
+	frozen, ok := Freeze(v).(*Vector)
+	if !ok || !frozen.Const || frozen == v {
+		t.Fatalf("expected the constant copy, got %v", frozen)
+	}
+	if inner := frozen.Elems[0].(Pair).This; inner != frozen {
+		t.Errorf("the copy of the cyclic vector is not cyclic: %v", inner)
+	}
+	if got := frozen.String(); got != "#0=#((#0#) 2)" {
+		t.Errorf("unexpected %s", got)
+	}
+}
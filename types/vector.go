@@ -0,0 +1,154 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Vector is the fixed-size sequence of values, its elements can be changed,
+// so it is shared by the pointer
+type Vector struct {
+	Elems []any
+	// the constant vectors, like the literals, cannot be changed
+	Const bool
+}
+
+func NewVector(elems ...any) *Vector {
+	return &Vector{Elems: elems}
+}
+
+// Create new Vector by applying the function to all its elements,
+// the result is constant if the vector is constant
+func (v *Vector) Map(fn func(any) any) *Vector {
+	acc := make([]any, len(v.Elems))
+	for i, e := range v.Elems {
+		acc[i] = fn(e)
+	}
+	return &Vector{acc, v.Const}
+}
+
+// For at least one element of the Vector, the function is true
+func (v *Vector) Any(fn func(any) bool) bool {
+	for _, e := range v.Elems {
+		if fn(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Vector) String() string {
+	if val, ok := labelCycles(v); ok {
+		return ToString(val)
+	}
+	var acc []string
+	for _, e := range v.Elems {
+		acc = append(acc, ToString(e))
+	}
+	return fmt.Sprintf("#(%s)", strings.Join(acc, " "))
+}
+
+// Freeze returns the value where the vectors and the bytevectors that can be changed
+// are replaced by their constant copies, the value is returned unchanged when it has
+// no such vectors
+func Freeze(v any) any {
+	v, _ = FreezeCopy(v)
+	return v
+}
+
+// Same as Freeze, but also tells if anything was copied, the values that were not
+// copied cannot change anymore
+func FreezeCopy(v any) (any, bool) {
+	return freeze(v, nil)
+}
+
+// The vectors that were already copied are remembered, so the copies
+// of the cyclic vectors are cyclic as well
+func freeze(v any, seen map[*Vector]*Vector) (any, bool) {
+	switch v := v.(type) {
+	case *Vector:
+		if v.Const {
+			return v, false
+		}
+		if c, ok := seen[v]; ok {
+			return c, true
+		}
+		if seen == nil {
+			seen = make(map[*Vector]*Vector)
+		}
+		c := &Vector{Const: true}
+		seen[v] = c
+		if len(v.Elems) > 0 {
+			c.Elems = make([]any, len(v.Elems))
+		}
+		for i, e := range v.Elems {
+			c.Elems[i], _ = freeze(e, seen)
+		}
+		return c, true
+	case *Bytevector:
+		if v.Const {
+			return v, false
+		}
+		return &Bytevector{slices.Clone(v.Bytes), true}, true
+	case Pair:
+		head, ok1 := freeze(v.This, seen)
+		tail, ok2 := freeze(v.Next, seen)
+		if !ok1 && !ok2 {
+			return v, false
+		}
+		return Pair{head, tail}, true
+	default:
+		return v, false
+	}
+}
+
+// The value where the vectors that contain themselves are labelled, and the references
+// to them are replaced with the backreferences, as for the cyclic terms `#0=#(a #0#)`,
+// it is false when there are no cycles
+func labelCycles(v any) (any, bool) {
+	l := &vectorLabeller{}
+	v = l.label(v)
+	return v, l.labels > 0
+}
+
+type vectorLabeller struct {
+	path   []*vectorFrame
+	labels int
+}
+
+type vectorFrame struct {
+	vec   *Vector
+	label *int
+}
+
+func (l *vectorLabeller) label(v any) any {
+	switch v := v.(type) {
+	case *Vector:
+		for _, frame := range l.path {
+			if frame.vec != v {
+				continue
+			}
+			if frame.label == nil {
+				label := l.labels
+				l.labels++
+				frame.label = &label
+			}
+			return Backref(*frame.label)
+		}
+		frame := &vectorFrame{vec: v}
+		l.path = append(l.path, frame)
+		out := v.Map(l.label)
+		l.path = l.path[:len(l.path)-1]
+		if frame.label != nil {
+			return Labelled{*frame.label, out}
+		}
+		return out
+	case Pair:
+		return Cons(v.Map(l.label)...)
+	case Labelled:
+		return Labelled{v.Label, l.label(v.Value)}
+	default:
+		return v
+	}
+}